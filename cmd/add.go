@@ -9,13 +9,29 @@ import (
 )
 
 var (
-	addUser string
-	addHost string
-	addPort int
-	addNote string
-	addTags string
+	addUser     string
+	addHost     string
+	addPort     int
+	addNote     string
+	addTags     string
+	addIdentity string
+	addJump     string
+	addVia      string
 )
 
+// hostSpec 是写入 hosts 表的一条完整记录（add / import 共用）
+type hostSpec struct {
+	Name         string
+	User         string
+	Host         string
+	Port         int
+	Note         string
+	Tags         []string
+	IdentityFile string
+	ProxyJump    string
+	Jumps        []string // 跳板机链路（sshmgr 主机名），和 ProxyJump 二选一
+}
+
 var addCmd = &cobra.Command{
 	Use:   "add <name>",
 	Short: "Add a Mac target (recommended host: xxx.local)",
@@ -29,17 +45,21 @@ var addCmd = &cobra.Command{
 			return fmt.Errorf("invalid --port: %s", strconv.Itoa(addPort))
 		}
 
-		_, err := DB.Exec(`
-INSERT INTO hosts(name,user,host,port,note,tags,created_at)
-VALUES(?,?,?,?,?,?,?)
-ON CONFLICT(name) DO UPDATE SET
-  user=excluded.user,
-  host=excluded.host,
-  port=excluded.port,
-  note=excluded.note,
-  tags=excluded.tags
-`, name, addUser, addHost, addPort, addNote, addTags, db.NowUTC())
-		return err
+		if addVia != "" && addJump != "" {
+			return fmt.Errorf("--jump and --proxy-jump are mutually exclusive")
+		}
+
+		return upsertHost(hostSpec{
+			Name:         name,
+			User:         addUser,
+			Host:         addHost,
+			Port:         addPort,
+			Note:         addNote,
+			Tags:         parseTags(addTags),
+			IdentityFile: addIdentity,
+			ProxyJump:    addJump,
+			Jumps:        parseTags(addVia),
+		})
 	},
 }
 
@@ -49,4 +69,68 @@ func init() {
 	addCmd.Flags().IntVar(&addPort, "port", 22, "ssh port")
 	addCmd.Flags().StringVar(&addNote, "note", "", "note")
 	addCmd.Flags().StringVar(&addTags, "tags", "", "tags (comma-separated)")
+	addCmd.Flags().StringVar(&addIdentity, "identity-file", "", "ssh identity file (same as `opt set <name> IdentityFile`)")
+	addCmd.Flags().StringVar(&addJump, "proxy-jump", "", "raw ssh ProxyJump (-J) for hops not managed by sshmgr")
+	addCmd.Flags().StringVar(&addVia, "jump", "", "jump chain of sshmgr host names (comma-separated, in order)")
+}
+
+func upsertHost(h hostSpec) error {
+	if err := checkJumps(h.Name, h.Jumps); err != nil {
+		return err
+	}
+	for _, t := range h.Tags {
+		if err := checkTag(t); err != nil {
+			return err
+		}
+	}
+	_, err := DB.Exec(`
+INSERT INTO hosts(name,user,host,port,note,proxy_jump,created_at)
+VALUES(?,?,?,?,?,?,?)
+ON CONFLICT(name) DO UPDATE SET
+  user=excluded.user,
+  host=excluded.host,
+  port=excluded.port,
+  note=excluded.note,
+  proxy_jump=excluded.proxy_jump
+`, h.Name, h.User, h.Host, h.Port, h.Note, h.ProxyJump, db.NowUTC())
+	if err != nil {
+		return err
+	}
+	id, err := hostID(h.Name)
+	if err != nil {
+		return err
+	}
+	if err := setHostTags(id, h.Tags); err != nil {
+		return err
+	}
+	// IdentityFile 存在 host_options 里
+	if h.IdentityFile != "" {
+		err = setHostOption(id, "IdentityFile", h.IdentityFile)
+	} else {
+		_, err = DB.Exec(`DELETE FROM host_options WHERE host_id=? AND key='IdentityFile'`, id)
+	}
+	if err != nil {
+		return err
+	}
+	return setHostJumps(id, h.Jumps, false)
+}
+
+// loadHostSpec 读取已有记录；不存在时返回 sql.ErrNoRows。
+func loadHostSpec(name string) (hostSpec, error) {
+	h := hostSpec{Name: name}
+	var id int64
+	err := DB.QueryRow(`SELECT id,user,host,port,note,proxy_jump FROM hosts WHERE name=?`, name).
+		Scan(&id, &h.User, &h.Host, &h.Port, &h.Note, &h.ProxyJump)
+	if err != nil {
+		return h, err
+	}
+	if h.IdentityFile, err = identityFile(id); err != nil {
+		return h, err
+	}
+	if h.Tags, err = hostTags(id); err != nil {
+		return h, err
+	}
+	g, err := loadJumpGraph()
+	h.Jumps = g[name]
+	return h, err
 }
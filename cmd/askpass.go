@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/app"
+	"sshmgr/internal/sys"
+)
+
+// ssh 把 SSH_ASKPASS 当作程序路径直接执行（不能带参数），
+// 所以写一个小脚本转调 `sshmgr askpass`。
+const (
+	askpassTokenEnv = "SSHMGR_ASKPASS_TOKEN"
+	askpassTTL      = 2 * time.Minute
+)
+
+// askpassToken 存在 run/askpass-<sha256(token)>.json，只能用一次
+type askpassToken struct {
+	Name    string
+	User    string
+	Backend string
+	Expires time.Time
+
+	// Target 是 ssh 提示里的 user@addr；Jumped 表示经过跳板机，
+	// 这时看不出是哪台机器在要密码的提示一律不回答
+	Target string
+	Jumped bool
+}
+
+var askpassCmd = &cobra.Command{
+	Use:          "askpass [prompt]",
+	Short:        "SSH_ASKPASS helper for `ssh --auto-password`",
+	Hidden:       true,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true, // 错误信息会显示在 ssh 的输出里
+	// 不打开数据库
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prompt := ""
+		if len(args) == 1 {
+			prompt = args[0]
+		}
+		// 只回答密码提示；host key 确认、密钥口令等返回失败，ssh 会当作取消，
+		// token 留给随后的密码提示
+		if !strings.Contains(strings.ToLower(prompt), "password") {
+			return fmt.Errorf("askpass: not a password prompt: %q", prompt)
+		}
+
+		tok, err := peekAskpassToken(os.Getenv(askpassTokenEnv))
+		if err != nil {
+			return err
+		}
+		// 密码只给目标主机，不能被跳板机的提示拿走
+		lp := strings.ToLower(prompt)
+		if strings.Contains(lp, "@") || tok.Jumped {
+			if !strings.Contains(lp, strings.ToLower(tok.Target)) {
+				return fmt.Errorf("askpass: prompt is not for %s: %q", tok.Target, prompt)
+			}
+		}
+		if tok, err = takeAskpassToken(os.Getenv(askpassTokenEnv)); err != nil {
+			return err
+		}
+		store, err := sys.NewSecretStore(tok.Backend)
+		if err != nil {
+			return err
+		}
+		pw, err := store.Get(tok.Name, tok.User)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(os.Stdout, pw)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(askpassCmd)
+}
+
+func askpassTokenPath(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(app.ConfigDir(), "run", "askpass-"+hex.EncodeToString(sum[:])+".json")
+}
+
+// peekAskpassToken 读 token 文件但不删除；过期或已用过的 token 无效
+func peekAskpassToken(token string) (askpassToken, error) {
+	var tok askpassToken
+	if token == "" {
+		return tok, fmt.Errorf("askpass: %s is not set", askpassTokenEnv)
+	}
+	b, err := os.ReadFile(askpassTokenPath(token))
+	if err != nil {
+		return tok, errors.New("askpass: token is invalid or already used")
+	}
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return tok, err
+	}
+	if time.Now().After(tok.Expires) {
+		_ = os.Remove(askpassTokenPath(token))
+		return tok, errors.New("askpass: token expired")
+	}
+	return tok, nil
+}
+
+// takeAskpassToken 同 peekAskpassToken 并删除 token 文件。
+// 删除失败（已被别的进程拿走）也算无效，保证只用一次。
+func takeAskpassToken(token string) (askpassToken, error) {
+	tok, err := peekAskpassToken(token)
+	if err != nil {
+		return tok, err
+	}
+	if err := os.Remove(askpassTokenPath(token)); err != nil {
+		return tok, errors.New("askpass: token is invalid or already used")
+	}
+	return tok, nil
+}
+
+// askpassEnv 为一次 ssh 调用准备 askpass：生成一次性 token 和转调脚本，
+// 返回要加给 ssh 的环境变量；cleanup 删除没用掉的 token。
+func askpassEnv(h sshHost, t sshTarget) (env []string, cleanup func(), err error) {
+	var has bool
+	if err := DB.QueryRow(`SELECT has_secret FROM hosts WHERE id=?`, h.ID).Scan(&has); err != nil {
+		return nil, nil, err
+	}
+	if !has {
+		return nil, nil, fmt.Errorf("no password stored for %s (see `sshmgr pass set`)", h.Name)
+	}
+
+	script, err := writeAskpassScript()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, nil, err
+	}
+	token := hex.EncodeToString(raw)
+	b, err := json.Marshal(askpassToken{
+		Name:    h.Name,
+		User:    h.User,
+		Backend: secretBackend,
+		Expires: time.Now().Add(askpassTTL),
+		Target:  h.User + "@" + t.Addr,
+		Jumped:  h.jumpSpec() != "",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	path := askpassTokenPath(token)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return nil, nil, err
+	}
+
+	env = []string{
+		"SSH_ASKPASS=" + script,
+		"SSH_ASKPASS_REQUIRE=force",
+		askpassTokenEnv + "=" + token,
+	}
+	// 旧版 OpenSSH 不认 SSH_ASKPASS_REQUIRE，要有 DISPLAY 才会用 askpass
+	if os.Getenv("DISPLAY") == "" {
+		env = append(env, "DISPLAY=sshmgr:0")
+	}
+	return env, func() { _ = os.Remove(path) }, nil
+}
+
+// writeAskpassScript 写 run/askpass(.cmd)，内容是转调当前可执行文件
+func writeAskpassScript() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(app.ConfigDir(), "run")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "askpass")
+	content := "#!/bin/sh\nexec '" + strings.ReplaceAll(exe, "'", `'\''`) + "' askpass \"$@\"\n"
+	if runtime.GOOS == "windows" {
+		path += ".cmd"
+		content = "@\"" + exe + "\" askpass %*\r\n"
+	}
+	// 并发调用时先写临时文件再 rename
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, []byte(content), 0o700); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp, path)
+}
@@ -7,7 +7,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
-	"sshmgr/internal/db"
+	"sshmgr/internal/netutil"
 	"sshmgr/internal/netx"
 )
 
@@ -20,10 +20,12 @@ var checkCmd = &cobra.Command{
 
 		var (
 			id     int64
+			user   string
 			host   string
+			port   int
 			lastIP sql.NullString
 		)
-		err := DB.QueryRow(`SELECT id, host, last_ip FROM hosts WHERE name=?`, name).Scan(&id, &host, &lastIP)
+		err := DB.QueryRow(`SELECT id, user, host, port, last_ip FROM hosts WHERE name=?`, name).Scan(&id, &user, &host, &port, &lastIP)
 		if err != nil {
 			return err
 		}
@@ -32,11 +34,21 @@ var checkCmd = &cobra.Command{
 		defer cancel()
 
 		ip, err := netx.ResolveHost(ctx, host)
-		if err != nil {
-			return err
+		if err == nil && ip == "" {
+			err = fmt.Errorf("no IP resolved for host: %s", host)
 		}
-		if ip == "" {
-			return fmt.Errorf("no IP resolved for host: %s", host)
+		if err != nil {
+			// 解析不到时看邻居表里已知 MAC 现在在哪个 IP 上
+			fps, ferr := knownFingerprints(id, name)
+			if ferr != nil {
+				return ferr
+			}
+			mip := findByMAC(id, user, host, port, fps)
+			if mip == "" {
+				return err
+			}
+			fmt.Printf("%s: %v; found by MAC at %s\n", host, err, mip)
+			ip = mip
 		}
 
 		if lastIP.Valid && lastIP.String != "" && lastIP.String != ip {
@@ -45,7 +57,22 @@ var checkCmd = &cobra.Command{
 			fmt.Printf("IP: %s\n", ip)
 		}
 
-		_, err = DB.Exec(`UPDATE hosts SET last_ip=?, last_checked_at=? WHERE id=?`, ip, db.NowUTC(), id)
-		return err
+		// host key 确认是这台主机才记录 IP 和版本：strict 下拒绝，warn 下只提示
+		matched, err := checkHostKey(name, id, ip, port)
+		if err != nil || !matched {
+			return err
+		}
+		if err := recordIP(id, ip, "check"); err != nil {
+			return err
+		}
+
+		b, err := netutil.SSHBanner(ip, port, 2*time.Second)
+		if err != nil {
+			fmt.Printf("SSH: %v\n", err)
+			return nil
+		}
+		fmt.Printf("SSH: %s\n", b.Raw)
+		recordServerVersion(id, b.Raw)
+		return nil
 	},
 }
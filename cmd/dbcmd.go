@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/db"
+)
+
+var (
+	dbMigrateStatus bool
+	dbMigrateDryRun bool
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Database maintenance (schema migrations)",
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations (backs up the db first)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dbMigrateStatus {
+			return printMigrateStatus()
+		}
+
+		pending, err := db.Pending(DB)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("schema is up to date")
+			return nil
+		}
+
+		if dbMigrateDryRun {
+			for _, m := range pending {
+				fmt.Printf("-- %d: %s\n%s\n", m.Version, m.Name, m.Up)
+			}
+			fmt.Printf("dry-run: %d migration(s) pending\n", len(pending))
+			return nil
+		}
+
+		applied, err := migrateWithBackup(DB)
+		for _, m := range applied {
+			fmt.Printf("applied %d: %s\n", m.Version, m.Name)
+		}
+		return err
+	},
+}
+
+func init() {
+	dbMigrateCmd.Flags().BoolVar(&dbMigrateStatus, "status", false, "show applied/pending migrations and exit")
+	dbMigrateCmd.Flags().BoolVar(&dbMigrateDryRun, "dry-run", false, "print pending migrations without applying")
+	dbCmd.AddCommand(dbMigrateCmd)
+}
+
+func printMigrateStatus() error {
+	applied, err := db.Applied(DB)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED_AT")
+	for _, m := range db.Migrations() {
+		at, ok := applied[m.Version]
+		if !ok {
+			at = "pending"
+		} else if t, e := time.Parse(time.RFC3339, at); e == nil {
+			at = t.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, at)
+	}
+	return w.Flush()
+}
+
+// migrateWithBackup 在有待执行的迁移且库里已有数据时，先 VACUUM INTO 一份备份再升级。
+func migrateWithBackup(d *sql.DB) ([]db.Migration, error) {
+	pending, err := db.Pending(d)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	has, err := db.HasData(d)
+	if err != nil {
+		return nil, err
+	}
+	if has {
+		bak := fmt.Sprintf("%s.bak-%s", dbPath, time.Now().Format("20060102-150405"))
+		if err := db.Backup(d, bak); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "db backup: %s\n", bak)
+	}
+
+	return db.Migrate(d)
+}
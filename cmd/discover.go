@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
 	"os/user"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,13 +18,20 @@ import (
 	"github.com/spf13/cobra"
 
 	"sshmgr/internal/db"
+	"sshmgr/internal/mdns"
+	"sshmgr/internal/netif"
 	"sshmgr/internal/netx"
+	"sshmgr/internal/probe"
+	"sshmgr/internal/selector"
+	"sshmgr/internal/sshclient"
+	"sshmgr/internal/sshconfig"
 )
 
 var (
 	discoverTimeout int
 	discoverAdd     bool
 	discoverUser    string
+	discoverTags    string
 
 	discoverProbe       bool
 	discoverOnly        string
@@ -32,11 +39,6 @@ var (
 	discoverProbeTO     int
 )
 
-type discItem struct {
-	Instance string
-	Domain   string
-}
-
 type discFound struct {
 	Instance string
 	Host     string
@@ -44,13 +46,19 @@ type discFound struct {
 	IP       string
 	Domain   string
 
-	Status string // OK/AUTH/DENY/DOWN/ERR
+	Status string // OK/AUTH/DENY/DOWN/ERR，见 probe.Result.Status
+	Probe  probe.Result
 }
 
 var discoverCmd = &cobra.Command{
 	Use:   "discover",
 	Short: "Discover SSH-enabled devices on the LAN (Bonjour: _ssh._tcp)",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, t := range parseTags(discoverTags) {
+			if err := checkTag(t); err != nil {
+				return err
+			}
+		}
 		if discoverTimeout <= 0 {
 			discoverTimeout = 3
 		}
@@ -75,50 +83,47 @@ var discoverCmd = &cobra.Command{
 		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(discoverTimeout)*time.Second)
 		defer cancel()
 
-		items, err := browseSSH(ctx, "local.")
+		found, err := browseSSH(ctx, "local.")
 		if err != nil {
 			return err
 		}
-		if len(items) == 0 {
+		if len(found) == 0 {
 			fmt.Println("no _ssh._tcp services found (try increasing --timeout)")
 			return nil
 		}
 
-		found := make([]discFound, 0, len(items))
-		for _, it := range items {
-			h, p, err := lookupSSH(it)
-			if err != nil {
-				continue
-			}
-			ip := ""
-			rctx, rcancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
-			if v, err := netx.ResolveHost(rctx, h); err == nil {
-				ip = v
-			}
-			rcancel()
-
-			found = append(found, discFound{
-				Instance: it.Instance,
-				Host:     h,
-				Port:     p,
-				IP:       ip,
-				Domain:   it.Domain,
-				Status:   "",
-			})
-		}
-
 		// probe：并发探测
 		if discoverProbe {
 			if u == "" {
 				return fmt.Errorf("cannot determine --user for probe, please pass --user")
 			}
-			probeAll(found, u, discoverProbeTO, discoverConcurrency)
+			// 新发现的主机只有全局默认选项可用
+			opts, err := defaultOptions()
+			if err != nil {
+				return err
+			}
+			probeAll(found, u, sshconfig.NonInteractive(opts), discoverProbeTO, discoverConcurrency)
+		}
+
+		sel, err := selector.Parse(selectExpr)
+		if err != nil {
+			return err
 		}
 
 		// 过滤输出/添加
 		filtered := make([]discFound, 0, len(found))
 		for _, f := range found {
-			if passOnlyFilter(f.Status, discoverOnly) {
+			if !passOnlyFilter(f.Status, discoverOnly) {
+				continue
+			}
+			// 新发现的机器还没有标签，tag 条件只对 --tags 生效
+			h := selector.Host{
+				Name: slugify(preferNameFromHostOrInstance(f.Host, f.Instance)),
+				User: u,
+				Host: f.Host,
+				Tags: parseTags(discoverTags),
+			}
+			if sel.Match(h) {
 				filtered = append(filtered, f)
 			}
 		}
@@ -126,9 +131,10 @@ var discoverCmd = &cobra.Command{
 		// 输出
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 		if discoverProbe {
-			fmt.Fprintln(w, "NAME\tHOST\tPORT\tIP\tST")
+			fmt.Fprintln(w, "NAME\tHOST\tPORT\tIP\tST\tAUTH\tVERSION")
 			for _, f := range filtered {
-				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", f.Instance, f.Host, f.Port, f.IP, f.Status)
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", f.Instance, f.Host, f.Port, f.IP, f.Status,
+					strings.Join(f.Probe.AuthMethods, ","), f.Probe.ServerVersion)
 			}
 		} else {
 			fmt.Fprintln(w, "NAME\tHOST\tPORT\tIP")
@@ -161,6 +167,11 @@ ON CONFLICT(name) DO UPDATE SET
   host=excluded.host,
   port=excluded.port
 `, name, u, f.Host, f.Port, db.NowUTC())
+				if id, err := hostID(name); err == nil {
+					for _, t := range parseTags(discoverTags) {
+						_, _ = addHostTag(id, t)
+					}
+				}
 				added++
 			}
 			fmt.Printf("added/updated %d host(s) with user=%s\n", added, u)
@@ -174,73 +185,81 @@ func init() {
 	discoverCmd.Flags().IntVar(&discoverTimeout, "timeout", 3, "browse timeout seconds")
 	discoverCmd.Flags().BoolVar(&discoverAdd, "add", false, "add discovered hosts into sshmgr db")
 	discoverCmd.Flags().StringVar(&discoverUser, "user", "", "ssh user used with --add/--probe (default: current macOS user)")
+	discoverCmd.Flags().StringVar(&discoverTags, "tags", "", "tags (comma-separated) given to hosts added with --add")
+	selectFlag(discoverCmd)
+	localNetFlags(discoverCmd)
 
-	discoverCmd.Flags().BoolVar(&discoverProbe, "probe", false, "probe if the given user is connectable (OK/AUTH/DENY/DOWN)")
+	discoverCmd.Flags().BoolVar(&discoverProbe, "probe", false, "probe if the given user is connectable (OK/AUTH/DENY/DOWN/ERR)")
 	discoverCmd.Flags().StringVar(&discoverOnly, "only", "all", "filter: all|connectable|ok|auth|deny|down|err")
 	discoverCmd.Flags().IntVar(&discoverConcurrency, "concurrency", 20, "probe concurrency")
 	discoverCmd.Flags().IntVar(&discoverProbeTO, "probe-timeout", 2, "probe timeout seconds per host")
 }
 
-// 解析 dns-sd browse 输出：收集 instance + domain
-func browseSSH(ctx context.Context, domain string) ([]discItem, error) {
-	c := exec.CommandContext(ctx, "dns-sd", "-B", "_ssh._tcp", domain)
-	stdout, err := c.StdoutPipe()
+// browseSSH 用内置 mDNS 在每个本地局域网上浏览 _ssh._tcp，直到 ctx 超时；
+// 同一 host:port 只保留一条
+func browseSSH(ctx context.Context, domain string) ([]discFound, error) {
+	ifaces, err := netif.Interfaces(netPolicy())
 	if err != nil {
 		return nil, err
 	}
-	c.Stderr = os.Stderr
-
-	if err := c.Start(); err != nil {
+	if len(ifaces) == 0 && len(netIfaces) > 0 {
+		return nil, fmt.Errorf("no usable LAN on --iface %s (see `sshmgr net ifaces`)", strings.Join(netIfaces, ","))
+	}
+	// 一个都没识别出来时交给系统路由选网卡
+	entries, err := (&mdns.Client{Interfaces: ifaces}).Browse(ctx, "_ssh._tcp", domain)
+	if err != nil {
 		return nil, err
 	}
 
-	m := map[string]discItem{}
-	sc := bufio.NewScanner(stdout)
-	for sc.Scan() {
-		line := sc.Text()
-		fields := strings.Fields(line)
-		// time Add flags if domain _ssh._tcp. <instance...>
-		if len(fields) >= 7 && fields[1] == "Add" && fields[5] == "_ssh._tcp." {
-			inst := strings.Join(fields[6:], " ")
-			dom := fields[4]
-			key := inst + "|" + dom
-			m[key] = discItem{Instance: inst, Domain: dom}
+	seen := map[string]bool{}
+	var out []discFound
+	for e := range entries {
+		if e.Host == "" || e.Port == 0 {
+			continue
+		}
+		key := strings.ToLower(e.Host) + "|" + strconv.Itoa(e.Port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		ip := netx.PickOneIP(e.IPs)
+		if ip == "" {
+			rctx, rcancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+			if v, err := netx.ResolveHost(rctx, e.Host); err == nil {
+				ip = v
+			}
+			rcancel()
 		}
-	}
-	_ = c.Wait() // timeout/中止时可能非 0，忽略
 
-	out := make([]discItem, 0, len(m))
-	for _, v := range m {
-		out = append(out, v)
+		out = append(out, discFound{
+			Instance: e.Instance,
+			Host:     e.Host,
+			Port:     e.Port,
+			IP:       ip,
+			Domain:   e.Domain,
+		})
 	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Instance < out[j].Instance })
 	return out, nil
 }
 
-// dns-sd -L "<instance>" _ssh._tcp <domain> 解析到 host:port
-func lookupSSH(it discItem) (host string, port int, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	c := exec.CommandContext(ctx, "dns-sd", "-L", it.Instance, "_ssh._tcp", it.Domain)
-	out, _ := c.CombinedOutput()
-
-	re := regexp.MustCompile(`can be reached at\s+([^\s:]+):([0-9]+)`)
-	m := re.FindStringSubmatch(string(out))
-	if len(m) != 3 {
-		return "", 0, fmt.Errorf("no SRV record for %s", it.Instance)
+// probe
+func probeAll(found []discFound, user string, opts []sshconfig.Option, timeoutSeconds int, concurrency int) {
+	// 和 ssh 一样先试 agent 和密钥，能登录就是 OK
+	cfg := sshclient.Config{UseAgent: true}
+	for _, o := range opts {
+		if strings.EqualFold(o.Key, "IdentityFile") {
+			cfg.IdentityFiles = append(cfg.IdentityFiles, strings.Trim(o.Value, `"`))
+		}
 	}
-
-	h := strings.TrimSuffix(m[1], ".")
-	p := 0
-	fmt.Sscanf(m[2], "%d", &p)
-	if h == "" || p == 0 {
-		return "", 0, fmt.Errorf("invalid SRV for %s", it.Instance)
+	if len(cfg.IdentityFiles) == 0 {
+		cfg.IdentityFiles = sshclient.DefaultIdentityFiles()
 	}
-	return h, p, nil
-}
+	signers, closeAgent := sshclient.Signers(cfg)
+	defer closeAgent()
 
-// probe
-func probeAll(found []discFound, user string, timeoutSeconds int, concurrency int) {
 	type job struct {
 		idx int
 	}
@@ -251,10 +270,17 @@ func probeAll(found []discFound, user string, timeoutSeconds int, concurrency in
 		defer wg.Done()
 		for j := range jobs {
 			f := found[j.idx]
-			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
-			st := probeOne(ctx, user, f.Host, f.Port)
-			cancel()
-			found[j.idx].Status = st
+			addr := net.JoinHostPort(f.Host, strconv.Itoa(f.Port))
+			if f.IP != "" {
+				addr = net.JoinHostPort(f.IP, strconv.Itoa(f.Port))
+			}
+			r := probe.Probe(context.Background(), addr, probe.Options{
+				User:    user,
+				Timeout: time.Duration(timeoutSeconds) * time.Second,
+				Signers: signers,
+			})
+			found[j.idx].Status = r.Status()
+			found[j.idx].Probe = r
 		}
 	}
 
@@ -269,51 +295,6 @@ func probeAll(found []discFound, user string, timeoutSeconds int, concurrency in
 	wg.Wait()
 }
 
-func probeOne(ctx context.Context, user, host string, port int) string {
-	target := fmt.Sprintf("%s@%s", user, host)
-	args := []string{
-		"-p", strconv.Itoa(port),
-		"-o", "BatchMode=yes",
-		"-o", "NumberOfPasswordPrompts=0",
-		"-o", "ConnectTimeout=2",
-		// probe 只为分类，不要卡 hostkey 交互，也不要污染 known_hosts
-		"-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null",
-		target,
-		"exit",
-	}
-
-	c := exec.CommandContext(ctx, "ssh", args...)
-	out, err := c.CombinedOutput()
-	s := string(out)
-
-	if err == nil {
-		return "OK"
-	}
-
-	ls := strings.ToLower(s)
-
-	// macOS/sshd 常见：User xxx not allowed because not listed in AllowUsers
-	if strings.Contains(ls, "not allowed") || strings.Contains(ls, "allowusers") {
-		return "DENY"
-	}
-	if strings.Contains(ls, "permission denied") {
-		// 这里大概率是：允许该 user，但需要密码/密钥
-		return "AUTH"
-	}
-	if strings.Contains(ls, "connection timed out") ||
-		strings.Contains(ls, "operation timed out") ||
-		strings.Contains(ls, "no route to host") ||
-		strings.Contains(ls, "connection refused") {
-		return "DOWN"
-	}
-	if strings.Contains(ls, "could not resolve hostname") ||
-		strings.Contains(ls, "name or service not known") {
-		return "ERR"
-	}
-	return "ERR"
-}
-
 func passOnlyFilter(status, only string) bool {
 	switch only {
 	case "all":
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/sshclient"
+	"sshmgr/internal/sshutil"
+	"sshmgr/internal/sys"
+)
+
+// sshEngine: openssh 调用系统 ssh；native 用内置客户端（internal/sshclient）
+var sshEngine string
+
+// nativeOptions 是内置客户端认的 ssh 选项，其他选项会被忽略
+var nativeOptions = map[string]bool{
+	"identityfile":   true,
+	"forwardagent":   true,
+	"setenv":         true,
+	"connecttimeout": true,
+	"remotecommand":  true,
+}
+
+// nativeSession 是一次内置客户端连接的参数
+type nativeSession struct {
+	Config       sshclient.Config
+	Targets      []sshclient.Target // 跳板机在前，目标主机在最后
+	Env          map[string]string
+	ForwardAgent bool
+	Command      string   // RemoteCommand
+	Ignored      []string // 内置客户端不支持、被忽略的选项
+}
+
+func engineFlag(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().StringVar(&sshEngine, "engine", "openssh", "ssh implementation: openssh|native (built-in client)")
+	}
+}
+
+func useNative() (bool, error) {
+	switch sshEngine {
+	case "", "openssh":
+		return false, nil
+	case "native":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid --engine: %s (want openssh|native)", sshEngine)
+}
+
+// newNativeSession 把主机、跳板机和选项转换成 sshclient 的参数。
+// interactive 为 false 时（exec）不会在终端提示输入密码。
+func newNativeSession(h sshHost, t sshTarget, timeout time.Duration, interactive bool) (nativeSession, error) {
+	s := nativeSession{
+		Config: sshclient.Config{UseAgent: true, Timeout: timeout},
+		Env:    map[string]string{},
+	}
+	if len(h.Jumps) == 0 && h.ProxyJump != "" {
+		return s, fmt.Errorf("%s: --engine native only follows jump hosts set with `sshmgr jump set`, not proxy_jump %q", h.Name, h.ProxyJump)
+	}
+
+	for _, o := range h.Options {
+		v := strings.Trim(o.Value, `"`)
+		switch strings.ToLower(o.Key) {
+		case "identityfile":
+			s.Config.IdentityFiles = append(s.Config.IdentityFiles, v)
+		case "forwardagent":
+			s.ForwardAgent = strings.EqualFold(v, "yes")
+		case "setenv":
+			for _, kv := range strings.Fields(o.Value) {
+				if k, val, ok := strings.Cut(kv, "="); ok {
+					s.Env[k] = strings.Trim(val, `"`)
+				}
+			}
+		case "connecttimeout":
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				s.Config.Timeout = time.Duration(n) * time.Second
+			}
+		case "remotecommand":
+			s.Command = o.Value
+		}
+		if !nativeOptions[strings.ToLower(o.Key)] {
+			s.Ignored = append(s.Ignored, o.Key)
+		}
+	}
+	if len(s.Config.IdentityFiles) == 0 {
+		s.Config.IdentityFiles = sshclient.DefaultIdentityFiles()
+	}
+
+	for _, j := range h.Jumps {
+		target, err := nativeTarget(j, j.Host, interactive)
+		if err != nil {
+			return s, err
+		}
+		s.Targets = append(s.Targets, target)
+	}
+	target, err := nativeTarget(h, t.Addr, interactive)
+	if err != nil {
+		return s, err
+	}
+	s.Targets = append(s.Targets, target)
+	return s, nil
+}
+
+func nativeTarget(h sshHost, addr string, interactive bool) (sshclient.Target, error) {
+	cb, algos, err := nativeHostKey(h)
+	if err != nil {
+		return sshclient.Target{}, err
+	}
+	return sshclient.Target{
+		User:              h.User,
+		Addr:              net.JoinHostPort(addr, strconv.Itoa(h.Port)),
+		HostKey:           cb,
+		HostKeyAlgorithms: algos,
+		Password:          nativePassword(h, interactive),
+	}, nil
+}
+
+// nativeHostKey 和 verifyHostKey 规则一致：没固定过就固定（TOFU），
+// 不一致按 --hostkey-policy 处理。已固定过时只接受固定的 key 类型。
+func nativeHostKey(h sshHost) (ssh.HostKeyCallback, []string, error) {
+	switch hostKeyPolicy {
+	case "strict", "warn":
+	case "off":
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid --hostkey-policy: %s (want strict|warn|off)", hostKeyPolicy)
+	}
+
+	pinned, err := loadHostKeys(h.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	algos := pinnedKeyAlgorithms(pinned)
+
+	cb := func(_ string, remote net.Addr, key ssh.PublicKey) error {
+		k := sshutil.HostKey{Type: key.Type(), Fingerprint: ssh.FingerprintSHA256(key)}
+		old, ok := pinned[k.Type]
+		switch {
+		case !ok:
+			if err := pinHostKey(h.ID, k); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "pinned host key for %s: %s %s\n", h.Name, k.Type, k.Fingerprint)
+		case old != k.Fingerprint:
+			return hostKeyChanged(h.Name, remote.String(), []hostKeyChange{{Type: k.Type, Old: old, New: k.Fingerprint}})
+		default:
+			_, _ = DB.Exec(`UPDATE host_keys SET last_seen_at=? WHERE host_id=? AND key_type=?`, db.NowUTC(), h.ID, k.Type)
+		}
+		return nil
+	}
+	return cb, algos, nil
+}
+
+// nativePassword 优先用 secret backend 里存的密码，交互式时再提示输入
+func nativePassword(h sshHost, interactive bool) func() (string, error) {
+	return func() (string, error) {
+		var has bool
+		_ = DB.QueryRow(`SELECT has_secret FROM hosts WHERE id=?`, h.ID).Scan(&has)
+		if has {
+			store, err := secretStore()
+			if err != nil {
+				return "", err
+			}
+			pw, err := store.Get(h.Name, h.User)
+			if err == nil {
+				return pw, nil
+			}
+			if !errors.Is(err, sys.ErrSecretNotFound) {
+				return "", err
+			}
+			setHasSecret(h.Name, false)
+		}
+
+		fd := int(os.Stdin.Fd())
+		if !interactive || !term.IsTerminal(fd) {
+			return "", fmt.Errorf("no password stored for %s (see `sshmgr pass set`)", h.Name)
+		}
+		fmt.Fprintf(os.Stderr, "%s@%s's password: ", h.User, h.Host)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+}
+
+// describe 用于 --dry-run，例如 "user@a:22 -> user@b:22"
+func (s nativeSession) describe() string {
+	hops := make([]string, len(s.Targets))
+	for i, t := range s.Targets {
+		hops[i] = t.User + "@" + t.Addr
+	}
+	return strings.Join(hops, " -> ")
+}
+
+// shell 打开交互式会话，返回远端退出码
+func (s nativeSession) shell() (int, error) {
+	c, err := sshclient.Dial(s.Config, s.Targets...)
+	if err != nil {
+		return 255, err
+	}
+	defer c.Close()
+	return c.Shell(sshclient.ShellOptions{Env: s.Env, ForwardAgent: s.ForwardAgent, Command: s.Command})
+}
+
+// run 执行一条命令（不分配 PTY），返回远端退出码
+func (s nativeSession) run(command string, stdout, stderr io.Writer) (int, error) {
+	c, err := sshclient.Dial(s.Config, s.Targets...)
+	if err != nil {
+		return 255, err
+	}
+	defer c.Close()
+	return c.Run(command, s.Env, nil, stdout, stderr)
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
+	"sshmgr/internal/sshconfig"
+)
+
+var (
+	execTag         string
+	execConcurrency int
+	execResolve     string
+	execSubnet      string
+	execTimeout     int
+)
+
+type execResult struct {
+	Name string
+	Addr string
+	Path string
+	Exit int
+	MS   int64
+	Err  error
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec [name...|all] [--select expr] -- <command...>",
+	Short: "Run a command on several hosts concurrently (by name, tag or all)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash < 0 || dash == len(args) {
+			return fmt.Errorf("missing remote command, use: sshmgr exec <name...|all|--select expr> -- <command>")
+		}
+		names, remote := args[:dash], args[dash:]
+		if execTag != "" {
+			selectExpr = strings.TrimPrefix(selectExpr+",tag="+execTag, ",")
+		}
+		if len(names) == 0 && selectExpr == "" {
+			return fmt.Errorf("select hosts by name, 'all' or --select")
+		}
+		if _, err := useNative(); err != nil {
+			return err
+		}
+		if execConcurrency <= 0 {
+			execConcurrency = 10
+		}
+
+		hosts, err := selectExecHosts(names)
+		if err != nil {
+			return err
+		}
+		if len(hosts) == 0 {
+			fmt.Println("no hosts")
+			return nil
+		}
+
+		results := runExec(hosts, remote)
+
+		fmt.Println()
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "addr", Header: "ADDR"},
+			render.Column{Key: "resolve_path", Header: "VIA"},
+			render.Column{Key: "exit_code", Header: "EXIT"},
+			render.Column{Key: "duration_ms", Header: "MS"},
+			render.Column{Key: "error"},
+		)
+		fail := 0
+		for _, r := range results {
+			errText := ""
+			if r.Err != nil {
+				errText = r.Err.Error()
+			}
+			if r.Exit != 0 {
+				fail++
+			}
+			out.Add(r.Name, r.Addr, r.Path, r.Exit, r.MS, errText)
+		}
+		if err := writeRows(out); err != nil {
+			return err
+		}
+
+		if fail > 0 {
+			return fmt.Errorf("%d/%d host(s) failed", fail, len(results))
+		}
+		return nil
+	},
+}
+
+func init() {
+	execCmd.Flags().StringVar(&execTag, "tag", "", "shorthand for --select tag=<t>")
+	selectFlag(execCmd)
+	execCmd.Flags().IntVar(&execConcurrency, "concurrency", 10, "max hosts running at once")
+	execCmd.Flags().StringVar(&execResolve, "resolve", "hostname,last-ip", "resolution order (see ssh --resolve)")
+	execCmd.Flags().StringVar(&execSubnet, "subnet", "", "targets for the reassociate step: subnets, ranges, addresses (default: local LANs)")
+	execCmd.Flags().IntVar(&execTimeout, "connect-timeout", 5, "ssh ConnectTimeout seconds")
+	engineFlag(execCmd)
+	rootCmd.AddCommand(execCmd)
+}
+
+// selectExecHosts: names 为空或含 all 时取全部，再按 --select 过滤。
+func selectExecHosts(names []string) ([]sshHost, error) {
+	all := len(names) == 0
+	want := map[string]bool{}
+	for _, n := range names {
+		if n == "all" {
+			all = true
+		}
+		want[n] = true
+	}
+
+	cond, condArgs, err := selectSQL("h")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := DB.Query(`SELECT `+sshHostColumns+` FROM hosts h WHERE `+cond+` ORDER BY name`, condArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []sshHost
+	for rows.Next() {
+		h, err := scanSSHHost(rows)
+		if err != nil {
+			return nil, err
+		}
+		if !all && !want[h.Name] {
+			continue
+		}
+		delete(want, h.Name)
+		out = append(out, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	for i := range out {
+		if err := out[i].loadExtras(); err != nil {
+			return nil, err
+		}
+		// 批量执行不要 RemoteCommand/端口转发这类会话选项
+		out[i].Options = sshconfig.NonInteractive(out[i].Options)
+	}
+
+	// 指定了 --select 时名字可能只是被过滤掉了，不算错
+	delete(want, "all")
+	if selectExpr == "" {
+		for n := range want {
+			return nil, fmt.Errorf("not found: %s", n)
+		}
+	}
+	return out, nil
+}
+
+func runExec(hosts []sshHost, remote []string) []execResult {
+	results := make([]execResult, len(hosts))
+	width := 0
+	for _, h := range hosts {
+		if len(h.Name) > width {
+			width = len(h.Name)
+		}
+	}
+
+	var outMu sync.Mutex
+	sem := make(chan struct{}, execConcurrency)
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		i, h := i, h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			prefix := fmt.Sprintf("%-*s | ", width, h.Name)
+			results[i] = execOne(h, remote, prefix, &outMu)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func execOne(h sshHost, remote []string, prefix string, outMu *sync.Mutex) execResult {
+	res := execResult{Name: h.Name, Exit: -1}
+
+	start := time.Now()
+	t, err := resolveSSHTarget(h, execResolve, execSubnet)
+	if err != nil {
+		// 解析不到也算一次执行，照样记进 conn_log
+		logConn(h.ID, start, time.Now(), sshTarget{Path: "unresolved"}, -1, strings.Join(remote, " "))
+		res.Err = err
+		return res
+	}
+	res.Addr, res.Path = t.Addr, t.Path
+
+	if sshEngine == "native" {
+		return execNative(h, t, remote, prefix, outMu, res)
+	}
+
+	args := h.sshArgs(t)
+	// 非交互：不弹密码/hostkey 提示，失败就直接报错
+	args = append([]string{
+		"-o", "BatchMode=yes",
+		"-o", fmt.Sprintf("ConnectTimeout=%d", execTimeout),
+	}, args...)
+	args = append(args, "--")
+	args = append(args, remote...)
+
+	c := exec.Command("ssh", args...)
+	stdout, _ := c.StdoutPipe()
+	stderr, _ := c.StderrPipe()
+
+	start = time.Now()
+	if err := c.Start(); err != nil {
+		res.Err = err
+		return res
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go prefixLines(&wg, outMu, os.Stdout, stdout, prefix)
+	go prefixLines(&wg, outMu, os.Stderr, stderr, prefix)
+	wg.Wait()
+
+	err = c.Wait()
+	end := time.Now()
+	res.Exit = exitCodeOf(err)
+	res.MS = end.Sub(start).Milliseconds()
+	if err != nil && res.Exit == -1 {
+		res.Err = err
+	}
+
+	logConn(h.ID, start, end, t, res.Exit, strings.Join(remote, " "))
+	return res
+}
+
+// execNative 同 execOne，用内置客户端执行（--engine native）
+func execNative(h sshHost, t sshTarget, remote []string, prefix string, outMu *sync.Mutex, res execResult) execResult {
+	s, err := newNativeSession(h, t, time.Duration(execTimeout)*time.Second, false)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	stdoutR, stdoutW := io.Pipe()
+	stderrR, stderrW := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go prefixLines(&wg, outMu, os.Stdout, stdoutR, prefix)
+	go prefixLines(&wg, outMu, os.Stderr, stderrR, prefix)
+
+	start := time.Now()
+	// 和 ssh 一样把参数用空格拼成一条命令交给远端 shell
+	res.Exit, res.Err = s.run(strings.Join(remote, " "), stdoutW, stderrW)
+	stdoutW.Close()
+	stderrW.Close()
+	wg.Wait()
+	end := time.Now()
+	res.MS = end.Sub(start).Milliseconds()
+
+	logConn(h.ID, start, end, t, res.Exit, strings.Join(remote, " "))
+	return res
+}
+
+func prefixLines(wg *sync.WaitGroup, mu *sync.Mutex, w io.Writer, r io.Reader, prefix string) {
+	defer wg.Done()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		mu.Lock()
+		fmt.Fprintf(w, "%s%s\n", prefix, sc.Text())
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/app"
+	"sshmgr/internal/sshconfig"
+)
+
+var (
+	exportFile           string
+	exportProxy          bool
+	exportInstallInclude bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export host entries for other tools",
+}
+
+var exportSSHConfigCmd = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Write a managed ssh_config include file (default ~/.ssh/config.d/sshmgr)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mode := "hostname"
+		if exportProxy {
+			mode = "proxy"
+		}
+
+		n, changed, err := writeSSHConfig(exportFile, mode)
+		if err != nil {
+			return err
+		}
+		if changed {
+			fmt.Printf("wrote %d host(s) to %s (mode=%s)\n", n, exportFile, mode)
+		} else {
+			fmt.Printf("%s is up to date (%d host(s))\n", exportFile, n)
+		}
+
+		if exportInstallInclude {
+			added, err := sshconfig.EnsureInclude(sshconfig.DefaultPath(), exportFile)
+			if err != nil {
+				return err
+			}
+			if added {
+				fmt.Printf("added Include %s to %s\n", exportFile, sshconfig.DefaultPath())
+			}
+		} else {
+			fmt.Printf("make sure %s starts with:\n  Include %s\n", sshconfig.DefaultPath(), exportFile)
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportSSHConfigCmd.Flags().StringVar(&exportFile, "file", sshconfig.DefaultExportPath(), "managed file to write")
+	exportSSHConfigCmd.Flags().BoolVar(&exportProxy, "proxy", false, "connect through `sshmgr proxy` (resolves host, falls back to last_ip)")
+	exportSSHConfigCmd.Flags().BoolVar(&exportInstallInclude, "install-include", false, "prepend an Include line to ~/.ssh/config if missing")
+	exportCmd.AddCommand(exportSSHConfigCmd)
+	rootCmd.AddCommand(exportCmd)
+
+	exportsSSHConfig(addCmd, rmCmd, importSSHConfigCmd, tagAddCmd, tagRmCmd, jumpSetCmd, jumpClearCmd,
+		optSetCmd, optUnsetCmd, reassociateCmd, keyPushCmd, keyRotateCmd)
+	// discover 只有 --add 时才改库
+	discoverCmd.PostRun = func(cmd *cobra.Command, args []string) {
+		if discoverAdd {
+			autoExportSSHConfig()
+		}
+	}
+}
+
+// writeSSHConfig 按当前 hosts 表生成 managed 文件；mode 为 hostname 或 proxy。
+func writeSSHConfig(path, mode string) (int, bool, error) {
+	// 跳板链路直接写成 ProxyJump <别名>，跳板机自己的配置也在同一个文件里
+	g, err := loadJumpGraph()
+	if err != nil {
+		return 0, false, err
+	}
+
+	defaults, err := defaultOptions()
+	if err != nil {
+		return 0, false, err
+	}
+	own := map[int64][]sshconfig.Option{}
+	optRows, err := DB.Query(`SELECT host_id,key,value FROM host_options ORDER BY host_id,key`)
+	if err != nil {
+		return 0, false, err
+	}
+	for optRows.Next() {
+		var id int64
+		var o sshconfig.Option
+		if err := optRows.Scan(&id, &o.Key, &o.Value); err != nil {
+			optRows.Close()
+			return 0, false, err
+		}
+		own[id] = append(own[id], o)
+	}
+	optRows.Close()
+	if err := optRows.Err(); err != nil {
+		return 0, false, err
+	}
+
+	rows, err := DB.Query(`SELECT id,name,user,host,port,proxy_jump,last_ip FROM hosts ORDER BY name`)
+	if err != nil {
+		return 0, false, err
+	}
+	defer rows.Close()
+
+	proxyCmd := ""
+	if mode == "proxy" {
+		proxyCmd, err = sshmgrProxyCommand()
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	var hosts []sshconfig.Host
+	for rows.Next() {
+		var id int64
+		var h sshconfig.Host
+		if err := rows.Scan(&id, &h.Alias, &h.User, &h.HostName, &h.Port, &h.ProxyJump, &h.LastIP); err != nil {
+			return 0, false, err
+		}
+		// 全局默认值逐个写进每台主机，不写 "Host *"，以免影响 ~/.ssh/config 里的其它主机
+		h.Options = sshconfig.Merge(defaults, own[id])
+		if hops := g[h.Alias]; len(hops) > 0 {
+			h.ProxyJump = strings.Join(hops, ",")
+		}
+		if proxyCmd != "" {
+			h.ProxyCommand = proxyCmd + " " + h.Alias
+		}
+		hosts = append(hosts, h)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, false, err
+	}
+
+	data := sshconfig.Render(hosts, "mode: "+mode, "db: "+dbPath)
+	changed, err := sshconfig.WriteManaged(path, data)
+	return len(hosts), changed, err
+}
+
+func sshmgrProxyCommand() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	parts := []string{shellQuote(exe)}
+	if dbPath != app.DefaultDBPath() {
+		parts = append(parts, "--db", shellQuote(dbPath))
+	}
+	parts = append(parts, "proxy")
+	return strings.Join(parts, " "), nil
+}
+
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\$") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// exportsSSHConfig 让修改主机或选项的命令成功后重新生成导出文件。
+// 只读命令和 ssh 每次连接都会跑的 proxy 不挂，免得反复读写。
+func exportsSSHConfig(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.PostRun = func(cmd *cobra.Command, args []string) { autoExportSSHConfig() }
+	}
+}
+
+// autoExportSSHConfig 在默认位置已有 managed 文件（用户执行过 export）时，
+// 按原 mode 重新生成；内容没变就不写。
+func autoExportSSHConfig() {
+	if DB == nil {
+		return
+	}
+	path := sshconfig.DefaultExportPath()
+	hdr, ok := sshconfig.ReadHeader(path)
+	if !ok || hdr["db"] != dbPath {
+		return
+	}
+	if _, _, err := writeSSHConfig(path, hdr["mode"]); err != nil {
+		fmt.Fprintf(os.Stderr, "ssh-config export: %v\n", err)
+	}
+}
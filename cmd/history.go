@@ -2,12 +2,10 @@ package cmd
 
 import (
 	"database/sql"
-	"fmt"
-	"os"
-	"text/tabwriter"
-	"time"
 
 	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
 )
 
 var (
@@ -23,47 +21,59 @@ var historyCmd = &cobra.Command{
 			histLimit = 20
 		}
 
+		cond, condArgs, err := selectSQL("h")
+		if err != nil {
+			return err
+		}
+		qArgs := append([]any{histName, histName}, condArgs...)
+		qArgs = append(qArgs, histLimit)
+
 		rows, err := DB.Query(`
-SELECT h.name,h.user,h.host,c.resolved_ip,c.end_at,c.duration_ms,c.exit_code
+SELECT h.name,h.user,h.host,c.resolved_ip,c.resolve_path,c.end_at,c.duration_ms,c.exit_code,c.command
 FROM conn_log c
 JOIN hosts h ON h.id=c.host_id
-WHERE (?='' OR h.name=?)
+WHERE (?='' OR h.name=?) AND `+cond+`
 ORDER BY c.id DESC
-LIMIT ?`, histName, histName, histLimit)
+LIMIT ?`, qArgs...)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tUSER\tHOST\tIP\tEND_AT\tDURATION_MS\tEXIT")
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "user", Header: "USER"},
+			render.Column{Key: "host", Header: "HOST"},
+			render.Column{Key: "ip", Header: "IP"},
+			render.Column{Key: "resolve_path", Header: "VIA"},
+			render.Column{Key: "end_at", Header: "END_AT", Format: localTimeFmt("2006-01-02 15:04:05")},
+			render.Column{Key: "duration_ms", Header: "DURATION_MS"},
+			render.Column{Key: "exit_code", Header: "EXIT"},
+			render.Column{Key: "command", Header: "CMD"},
+		)
 
 		for rows.Next() {
 			var name, user, host string
-			var ip, endAt sql.NullString
+			var ip, via, endAt, command sql.NullString
 			var dur int64
 			var exit int
 
-			if err := rows.Scan(&name, &user, &host, &ip, &endAt, &dur, &exit); err != nil {
+			if err := rows.Scan(&name, &user, &host, &ip, &via, &endAt, &dur, &exit, &command); err != nil {
 				return err
 			}
 
-			end := endAt.String
-			if endAt.Valid && endAt.String != "" {
-				if t, e := time.Parse(time.RFC3339, endAt.String); e == nil {
-					end = t.Local().Format("2006-01-02 15:04:05")
-				}
-			}
-
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%d\n", name, user, host, ip.String, end, dur, exit)
+			out.Add(name, user, host, ip.String, via.String, timeValue(endAt.String), dur, exit, command.String)
+		}
+		if err := rows.Err(); err != nil {
+			return err
 		}
 
-		_ = w.Flush()
-		return rows.Err()
+		return writeRows(out)
 	},
 }
 
 func init() {
 	historyCmd.Flags().StringVar(&histName, "name", "", "filter by host name")
 	historyCmd.Flags().IntVar(&histLimit, "limit", 20, "max rows")
+	selectFlag(historyCmd)
 }
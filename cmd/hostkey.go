@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/netx"
+	"sshmgr/internal/sshclient"
+	"sshmgr/internal/sshutil"
+)
+
+// hostKeyPolicy: strict 拒绝连接 / warn 只警告 / off 不做 keyscan
+var hostKeyPolicy string
+
+type hostKeyChange struct {
+	Type string
+	Old  string
+	New  string
+}
+
+type hostKeyResult struct {
+	Pinned     []sshutil.HostKey // 首次见到并已固定的 key
+	Mismatch   []hostKeyChange
+	Unverified bool // keyscan 失败、没拿到 key，或一个已固定的类型都没提供，无法确认
+}
+
+var hostkeyCmd = &cobra.Command{
+	Use:   "hostkey",
+	Short: "Show or re-pin stored SSH host keys (TOFU)",
+}
+
+var hostkeyShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show pinned host key fingerprints",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var id int64
+		if err := DB.QueryRow(`SELECT id FROM hosts WHERE name=?`, args[0]).Scan(&id); err != nil {
+			return err
+		}
+
+		rows, err := DB.Query(`SELECT key_type,fingerprint,first_seen_at,last_seen_at FROM host_keys WHERE host_id=? ORDER BY key_type`, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tFINGERPRINT\tFIRST_SEEN\tLAST_SEEN")
+		for rows.Next() {
+			var kt, fp, first, last string
+			if err := rows.Scan(&kt, &fp, &first, &last); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", kt, fp, localTime(first), localTime(last))
+		}
+		_ = w.Flush()
+		return rows.Err()
+	},
+}
+
+var hostkeyAcceptCmd = &cobra.Command{
+	Use:   "accept <name>",
+	Short: "Forget pinned host keys and pin the ones the host presents now",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		var (
+			id   int64
+			host string
+			port int
+		)
+		if err := DB.QueryRow(`SELECT id,host,port FROM hosts WHERE name=?`, name).Scan(&id, &host, &port); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		ip, err := netx.ResolveHost(ctx, host)
+		if err != nil {
+			return err
+		}
+		if ip == "" {
+			return fmt.Errorf("no IP resolved for host: %s", host)
+		}
+
+		keys, err := sshutil.HostKeys(ip, port)
+		if err != nil {
+			return fmt.Errorf("keyscan %s: %w", ip, err)
+		}
+
+		if _, err := DB.Exec(`DELETE FROM host_keys WHERE host_id=?`, id); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := pinHostKey(id, k); err != nil {
+				return err
+			}
+			fmt.Printf("pinned %s %s (%s)\n", k.Type, k.Fingerprint, ip)
+		}
+		if tbl := reassocTable(); tbl != nil {
+			tbl.Forget(name)
+		}
+		rememberHostKeys(name, ip, keys)
+		return nil
+	},
+}
+
+func init() {
+	hostkeyCmd.AddCommand(hostkeyShowCmd, hostkeyAcceptCmd)
+	rootCmd.PersistentFlags().StringVar(&hostKeyPolicy, "hostkey-policy", "strict", "host key change handling: strict|warn|off")
+	rootCmd.AddCommand(hostkeyCmd)
+}
+
+// verifyHostKey 对比 ip 上当前的 host key 和库里固定的 key：
+// 没见过的类型直接固定（TOFU），已固定且不一致的记为 mismatch。
+// keyscan 失败（主机不在线等）不算错误，返回 Unverified。
+// 一致或新固定的 key 同时记进 reassoc 表（以及 ip 的 MAC），变更的不记。
+func verifyHostKey(hostID int64, name, ip string, port int) (hostKeyResult, error) {
+	var res hostKeyResult
+	switch hostKeyPolicy {
+	case "strict", "warn":
+	case "off":
+		return res, nil
+	default:
+		return res, fmt.Errorf("invalid --hostkey-policy: %s (want strict|warn|off)", hostKeyPolicy)
+	}
+	if ip == "" {
+		return res, nil
+	}
+
+	keys, err := sshutil.HostKeys(ip, port)
+	if err != nil || len(keys) == 0 {
+		res.Unverified = true
+		return res, nil
+	}
+	return compareHostKeys(hostID, name, ip, keys)
+}
+
+// compareHostKeys 是 verifyHostKey 的比对部分，keys 是 ip 上刚看到的 key。
+// 已经固定过 key 时，至少要有一个固定过的类型一致，才会固定新出现的类型；
+// 否则只提供新类型的机器（比如占了这个 IP 的另一台）就能冒充过去。
+// 这种情况返回 Unverified，什么都不记。
+func compareHostKeys(hostID int64, name, ip string, keys []sshutil.HostKey) (hostKeyResult, error) {
+	var res hostKeyResult
+	pinned, err := loadHostKeys(hostID)
+	if err != nil {
+		return res, err
+	}
+
+	var matched, fresh []sshutil.HostKey
+	for _, k := range keys {
+		old, ok := pinned[k.Type]
+		switch {
+		case !ok:
+			fresh = append(fresh, k)
+		case old != k.Fingerprint:
+			res.Mismatch = append(res.Mismatch, hostKeyChange{Type: k.Type, Old: old, New: k.Fingerprint})
+		default:
+			matched = append(matched, k)
+		}
+	}
+	if len(res.Mismatch) > 0 {
+		return res, nil
+	}
+	if len(pinned) > 0 && len(matched) == 0 {
+		res.Unverified = true
+		return res, nil
+	}
+
+	for _, k := range matched {
+		_, _ = DB.Exec(`UPDATE host_keys SET last_seen_at=? WHERE host_id=? AND key_type=?`, db.NowUTC(), hostID, k.Type)
+	}
+	for _, k := range fresh {
+		if err := pinHostKey(hostID, k); err != nil {
+			return res, err
+		}
+		res.Pinned = append(res.Pinned, k)
+	}
+	trusted := append(matched, fresh...)
+	rememberHostKeys(name, ip, trusted)
+	if len(trusted) > 0 {
+		rememberMAC(hostID, ip)
+	}
+	return res, nil
+}
+
+// checkHostKey 打印固定/变更信息；strict 策略下遇到变更返回错误。
+// matched 表示可以认为 ip 上就是这台主机：host key 与已固定的一致（或刚固定），
+// 或 --hostkey-policy=off。没能确认（见 hostKeyResult.Unverified）时 matched 为 false、err 为 nil。
+func checkHostKey(name string, hostID int64, ip string, port int) (matched bool, err error) {
+	if hostKeyPolicy == "off" {
+		return true, nil
+	}
+	res, err := verifyHostKey(hostID, name, ip, port)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range res.Pinned {
+		fmt.Fprintf(os.Stderr, "pinned host key for %s: %s %s\n", name, k.Type, k.Fingerprint)
+	}
+	if res.Unverified {
+		fmt.Fprintf(os.Stderr, "could not verify the host key of %s at %s\n", name, ip)
+		return false, nil
+	}
+	if len(res.Mismatch) == 0 {
+		return true, nil
+	}
+	return false, hostKeyChanged(name, ip, res.Mismatch)
+}
+
+// hostKeyChanged 打印变更警告；strict 策略下返回错误。
+func hostKeyChanged(name, ip string, changes []hostKeyChange) error {
+	fmt.Fprintln(os.Stderr, "@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@")
+	fmt.Fprintf(os.Stderr, "@    WARNING: HOST KEY FOR %s (%s) HAS CHANGED!\n", name, ip)
+	fmt.Fprintln(os.Stderr, "@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@")
+	for _, c := range changes {
+		fmt.Fprintf(os.Stderr, "  %s\n    pinned:  %s\n    offered: %s\n", c.Type, c.Old, c.New)
+	}
+	fmt.Fprintf(os.Stderr, "The IP may have been reassigned to another machine. If the change is expected run:\n  sshmgr hostkey accept %s\n", name)
+
+	if hostKeyPolicy == "warn" {
+		return nil
+	}
+	return fmt.Errorf("host key mismatch for %s (refusing, --hostkey-policy=%s)", name, hostKeyPolicy)
+}
+
+func loadHostKeys(hostID int64) (map[string]string, error) {
+	rows, err := DB.Query(`SELECT key_type,fingerprint FROM host_keys WHERE host_id=?`, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]string{}
+	for rows.Next() {
+		var kt, fp string
+		if err := rows.Scan(&kt, &fp); err != nil {
+			return nil, err
+		}
+		out[kt] = fp
+	}
+	return out, rows.Err()
+}
+
+// pinnedKeyAlgorithms 把已固定的 key 类型转成握手时的 HostKeyAlgorithms，
+// 这样服务端不能换一种没固定过的类型绕过比对。没固定过时返回 nil（不限制）。
+func pinnedKeyAlgorithms(pinned map[string]string) []string {
+	types := make([]string, 0, len(pinned))
+	for kt := range pinned {
+		types = append(types, kt)
+	}
+	// ssh-ed25519 < ecdsa-* < ssh-rsa 的顺序跟 OpenSSH 的偏好一致
+	sort.Slice(types, func(i, j int) bool { return keyTypeRank(types[i]) < keyTypeRank(types[j]) })
+	var algos []string
+	for _, kt := range types {
+		algos = append(algos, sshclient.KeyAlgorithms(kt)...)
+	}
+	return algos
+}
+
+func keyTypeRank(kt string) int {
+	switch {
+	case kt == ssh.KeyAlgoED25519:
+		return 0
+	case strings.HasPrefix(kt, "ecdsa-"):
+		return 1
+	case kt == ssh.KeyAlgoRSA:
+		return 2
+	}
+	return 3
+}
+
+func pinHostKey(hostID int64, k sshutil.HostKey) error {
+	now := db.NowUTC()
+	_, err := DB.Exec(`
+INSERT INTO host_keys(host_id,key_type,fingerprint,first_seen_at,last_seen_at)
+VALUES(?,?,?,?,?)
+ON CONFLICT(host_id,key_type) DO UPDATE SET
+  fingerprint=excluded.fingerprint,
+  first_seen_at=excluded.first_seen_at,
+  last_seen_at=excluded.last_seen_at
+`, hostID, k.Type, k.Fingerprint, now, now)
+	return err
+}
+
+func localTime(s string) string {
+	if t, e := time.Parse(time.RFC3339, s); e == nil {
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+	return s
+}
+
+// hostKeyMatches 判断 ip 上的 host key 是否就是已固定的那台机器：
+// 至少一个类型一致且没有不一致的。没固定过 key 时无法证明身份，返回 false。
+func hostKeyMatches(hostID int64, ip string, port int) (bool, error) {
+	pinned, err := loadHostKeys(hostID)
+	if err != nil || len(pinned) == 0 {
+		return false, err
+	}
+
+	keys, err := sshutil.HostKeys(ip, port)
+	if err != nil {
+		return false, nil
+	}
+
+	matched := false
+	for _, k := range keys {
+		old, ok := pinned[k.Type]
+		if !ok {
+			continue
+		}
+		if old != k.Fingerprint {
+			return false, nil
+		}
+		matched = true
+	}
+	return matched, nil
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/sshutil"
+)
+
+// testDB 在临时目录里建一个迁移好的库并替换全局 DB；
+// HOME 也指到临时目录，reassoc 表等不会写到真实的配置目录
+func testDB(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	d, err := sql.Open("sqlite", filepath.Join(dir, "sshmgr.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Init(d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Migrate(d); err != nil {
+		t.Fatal(err)
+	}
+	old := DB
+	DB = d
+	reassocOnce, reassocTbl = sync.Once{}, nil
+	t.Cleanup(func() {
+		DB = old
+		d.Close()
+		reassocOnce, reassocTbl = sync.Once{}, nil
+	})
+}
+
+func testHost(t *testing.T, name string) int64 {
+	t.Helper()
+	res, err := DB.Exec(`INSERT INTO hosts(name,user,host,port,created_at) VALUES(?,?,?,?,?)`, name, "u", name+".local", 22, db.NowUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+var (
+	ed1   = sshutil.HostKey{Type: "ssh-ed25519", Fingerprint: "SHA256:ed-one"}
+	ed2   = sshutil.HostKey{Type: "ssh-ed25519", Fingerprint: "SHA256:ed-two"}
+	ecdsa = sshutil.HostKey{Type: "ecdsa-sha2-nistp256", Fingerprint: "SHA256:ecdsa"}
+	rsa   = sshutil.HostKey{Type: "ssh-rsa", Fingerprint: "SHA256:rsa"}
+)
+
+func TestCompareHostKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		pinned     []sshutil.HostKey
+		offered    []sshutil.HostKey
+		newPins    int
+		mismatch   int
+		unverified bool
+		after      map[string]string // 比对之后库里固定的 key
+	}{
+		{
+			name:    "first contact pins everything",
+			offered: []sshutil.HostKey{ed1, ecdsa},
+			newPins: 2,
+			after:   map[string]string{ed1.Type: ed1.Fingerprint, ecdsa.Type: ecdsa.Fingerprint},
+		},
+		{
+			name:    "match",
+			pinned:  []sshutil.HostKey{ed1},
+			offered: []sshutil.HostKey{ed1},
+			after:   map[string]string{ed1.Type: ed1.Fingerprint},
+		},
+		{
+			name:    "match pins a new type alongside",
+			pinned:  []sshutil.HostKey{ed1},
+			offered: []sshutil.HostKey{ed1, ecdsa},
+			newPins: 1,
+			after:   map[string]string{ed1.Type: ed1.Fingerprint, ecdsa.Type: ecdsa.Fingerprint},
+		},
+		{
+			// 占了 IP 的机器只给一种没固定过的类型：不能信，也不能固定
+			name:       "only new type offered",
+			pinned:     []sshutil.HostKey{ed1},
+			offered:    []sshutil.HostKey{ecdsa},
+			unverified: true,
+			after:      map[string]string{ed1.Type: ed1.Fingerprint},
+		},
+		{
+			name:     "mismatch does not pin the new type",
+			pinned:   []sshutil.HostKey{ed1},
+			offered:  []sshutil.HostKey{ed2, rsa},
+			mismatch: 1,
+			after:    map[string]string{ed1.Type: ed1.Fingerprint},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDB(t)
+			id := testHost(t, "mini")
+			for _, k := range tt.pinned {
+				if err := pinHostKey(id, k); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res, err := compareHostKeys(id, "mini", "192.0.2.10", tt.offered)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Pinned) != tt.newPins || len(res.Mismatch) != tt.mismatch || res.Unverified != tt.unverified {
+				t.Errorf("result = %+v, want %d pinned, %d mismatch, unverified %v", res, tt.newPins, tt.mismatch, tt.unverified)
+			}
+
+			got, err := loadHostKeys(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.after) {
+				t.Errorf("pinned keys = %v, want %v", got, tt.after)
+			}
+			for kt, fp := range tt.after {
+				if got[kt] != fp {
+					t.Errorf("pinned keys = %v, want %v", got, tt.after)
+					break
+				}
+			}
+
+			// reassoc 表只记被信任的 key
+			remembered := len(reassocTable().Fingerprints("mini")) > 0
+			if want := tt.mismatch == 0 && !tt.unverified; remembered != want {
+				t.Errorf("reassoc table has mini = %v, want %v", remembered, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os/user"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/sshconfig"
+)
+
+var (
+	importDryRun    bool
+	importOverwrite bool
+	importUser      string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import host entries from other tools",
+}
+
+var importSSHConfigCmd = &cobra.Command{
+	Use:   "ssh-config [path]",
+	Short: "Import Host entries from ssh_config (default ~/.ssh/config, follows Include)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := sshconfig.DefaultPath()
+		if len(args) == 1 {
+			path = args[0]
+		}
+
+		res, err := sshconfig.ParseFile(path)
+		if err != nil {
+			return err
+		}
+
+		defUser := importUser
+		if defUser == "" {
+			if me, e := user.Current(); e == nil {
+				defUser = me.Username
+			}
+		}
+
+		var added, updated, same, conflicts int
+		for _, h := range res.Hosts {
+			spec := hostSpec{
+				Name:         h.Alias,
+				User:         h.User,
+				Host:         h.HostName,
+				Port:         h.Port,
+				IdentityFile: h.IdentityFile,
+				ProxyJump:    h.ProxyJump,
+			}
+			if spec.User == "" {
+				spec.User = defUser
+			}
+			if spec.User == "" {
+				fmt.Printf("! %s: no User and cannot determine local user, skipped (%s)\n", h.Alias, h.Source)
+				continue
+			}
+
+			old, err := loadHostSpec(h.Alias)
+			if err == sql.ErrNoRows {
+				fmt.Printf("+ %s\t%s@%s:%d%s\n", spec.Name, spec.User, spec.Host, spec.Port, extraDesc(spec))
+				added++
+				if !importDryRun {
+					if err := upsertHost(spec); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// note/tags/跳板链路是 sshmgr 自己的字段，ssh_config 里没有，保留原值
+			spec.Note, spec.Tags, spec.Jumps = old.Note, old.Tags, old.Jumps
+			if len(old.Jumps) > 0 && spec.ProxyJump == strings.Join(old.Jumps, ",") {
+				// ssh_config 里的 ProxyJump 就是已有的跳板链路
+				spec.ProxyJump = ""
+			}
+			diff := hostDiff(old, spec)
+			if len(diff) == 0 {
+				same++
+				continue
+			}
+
+			if !importOverwrite {
+				fmt.Printf("! %s\tconflict: %s (keep, use --overwrite to replace)\n", spec.Name, strings.Join(diff, "; "))
+				conflicts++
+				continue
+			}
+			fmt.Printf("~ %s\t%s\n", spec.Name, strings.Join(diff, "; "))
+			updated++
+			if !importDryRun {
+				if err := upsertHost(spec); err != nil {
+					return err
+				}
+			}
+		}
+
+		prefix := ""
+		if importDryRun {
+			prefix = "dry-run: "
+		}
+		fmt.Printf("%s%d added, %d updated, %d unchanged, %d conflict(s) from %d file(s)\n",
+			prefix, added, updated, same, conflicts, len(res.Files))
+		if res.MatchBlocks > 0 || len(res.WildcardOnly) > 0 {
+			fmt.Printf("skipped %d Match block(s) and %d wildcard pattern(s) (no single host to import)\n",
+				res.MatchBlocks, len(res.WildcardOnly))
+		}
+		return nil
+	},
+}
+
+func init() {
+	importSSHConfigCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "show what would change without writing")
+	importSSHConfigCmd.Flags().BoolVar(&importOverwrite, "overwrite", false, "replace existing entries that differ")
+	importSSHConfigCmd.Flags().StringVar(&importUser, "user", "", "user for entries without User (default: current user)")
+	importCmd.AddCommand(importSSHConfigCmd)
+	rootCmd.AddCommand(importCmd)
+}
+
+func hostDiff(a, b hostSpec) []string {
+	var out []string
+	add := func(field, x, y string) {
+		if x != y {
+			out = append(out, fmt.Sprintf("%s: %q -> %q", field, x, y))
+		}
+	}
+	add("user", a.User, b.User)
+	add("host", a.Host, b.Host)
+	add("port", fmt.Sprint(a.Port), fmt.Sprint(b.Port))
+	add("identity_file", a.IdentityFile, b.IdentityFile)
+	add("proxy_jump", a.ProxyJump, b.ProxyJump)
+	return out
+}
+
+func extraDesc(h hostSpec) string {
+	var parts []string
+	if h.IdentityFile != "" {
+		parts = append(parts, "identity="+h.IdentityFile)
+	}
+	if h.ProxyJump != "" {
+		parts = append(parts, "jump="+h.ProxyJump)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/db"
+)
+
+var ipsShared bool
+
+var ipsCmd = &cobra.Command{
+	Use:   "ips [name]",
+	Short: "Show the IP address timeline of a host (flags IPs shared with other hosts)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if ipsShared || len(args) == 0 {
+			return printSharedIPs()
+		}
+
+		name := args[0]
+		var id int64
+		if err := DB.QueryRow(`SELECT id FROM hosts WHERE name=?`, name).Scan(&id); err != nil {
+			return err
+		}
+
+		rows, err := DB.Query(`
+SELECT
+  i.ip,
+  i.first_seen_at,
+  i.last_seen_at,
+  i.source,
+  i.seen_count,
+  (SELECT GROUP_CONCAT(DISTINCT h.name || CASE
+            WHEN o.first_seen_at <= i.last_seen_at AND o.last_seen_at >= i.first_seen_at THEN '!'
+            ELSE '' END)
+     FROM ip_history o JOIN hosts h ON h.id=o.host_id
+    WHERE o.ip=i.ip AND o.host_id<>i.host_id) AS shared
+FROM ip_history i
+WHERE i.host_id=?
+ORDER BY i.id DESC`, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tFIRST_SEEN\tLAST_SEEN\tSOURCE\tCOUNT\tSHARED_WITH")
+		conflicts := 0
+		for rows.Next() {
+			var ip, first, last, source string
+			var count int
+			var shared sql.NullString
+			if err := rows.Scan(&ip, &first, &last, &source, &count, &shared); err != nil {
+				return err
+			}
+			if strings.Contains(shared.String, "!") {
+				conflicts++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", ip, localTime(first), localTime(last), source, count, shared.String)
+		}
+		_ = w.Flush()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if conflicts > 0 {
+			fmt.Println("\n! = the other host held the same IP during an overlapping period (possible lease conflict)")
+		}
+		return nil
+	},
+}
+
+func init() {
+	ipsCmd.Flags().BoolVar(&ipsShared, "shared", false, "list every IP that has been held by more than one host")
+	rootCmd.AddCommand(ipsCmd)
+}
+
+func printSharedIPs() error {
+	rows, err := DB.Query(`
+SELECT i.ip, GROUP_CONCAT(DISTINCT h.name), MAX(i.last_seen_at)
+FROM ip_history i JOIN hosts h ON h.id=i.host_id
+GROUP BY i.ip
+HAVING COUNT(DISTINCT i.host_id) > 1
+ORDER BY MAX(i.last_seen_at) DESC`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IP\tHOSTS\tLAST_SEEN")
+	for rows.Next() {
+		var ip, names, last string
+		if err := rows.Scan(&ip, &names, &last); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ip, names, localTime(last))
+	}
+	_ = w.Flush()
+	return rows.Err()
+}
+
+// recordIP 更新 hosts.last_ip，并维护 ip_history：
+// 与该主机最近一条记录相同则延长，否则新开一段。source 为触发的命令名。
+func recordIP(hostID int64, ip, source string) error {
+	if ip == "" {
+		return nil
+	}
+	now := db.NowUTC()
+
+	if _, err := DB.Exec(`UPDATE hosts SET last_ip=?, last_checked_at=? WHERE id=?`, ip, now, hostID); err != nil {
+		return err
+	}
+
+	var (
+		histID int64
+		lastIP string
+	)
+	err := DB.QueryRow(`SELECT id, ip FROM ip_history WHERE host_id=? ORDER BY id DESC LIMIT 1`, hostID).Scan(&histID, &lastIP)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && lastIP == ip {
+		_, err = DB.Exec(`UPDATE ip_history SET last_seen_at=?, source=?, seen_count=seen_count+1 WHERE id=?`, now, source, histID)
+		return err
+	}
+
+	_, err = DB.Exec(`INSERT INTO ip_history(host_id,ip,first_seen_at,last_seen_at,source) VALUES(?,?,?,?,?)`,
+		hostID, ip, now, now, source)
+	return err
+}
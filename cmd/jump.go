@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
+)
+
+var jumpCmd = &cobra.Command{
+	Use:   "jump",
+	Short: "Manage jump host chains (bastions, multi-hop)",
+}
+
+var jumpSetCmd = &cobra.Command{
+	Use:   "set <name> <hop>...",
+	Short: "Reach <name> through the given sshmgr hosts, in order",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, hops := args[0], args[1:]
+		id, err := hostID(name)
+		if err != nil {
+			return err
+		}
+		if err := checkJumps(name, hops); err != nil {
+			return err
+		}
+		// 链路和原始 ProxyJump 只保留一个
+		if err := setHostJumps(id, hops, true); err != nil {
+			return err
+		}
+
+		chain, err := jumpChain(name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s via %s\n", name, strings.Join(chain, " -> "))
+		return nil
+	},
+}
+
+var jumpClearCmd = &cobra.Command{
+	Use:   "clear <name>",
+	Short: "Connect to <name> directly again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := hostID(args[0])
+		if err != nil {
+			return err
+		}
+		// 原始 ProxyJump（如 import 进来的）也要清掉，否则 jumpSpec 还会用它
+		return setHostJumps(id, nil, true)
+	},
+}
+
+var jumpLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List hosts that are reached through jump hosts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		g, err := loadJumpGraph()
+		if err != nil {
+			return err
+		}
+		rows, err := DB.Query(`SELECT name,proxy_jump FROM hosts WHERE proxy_jump<>'' OR id IN (SELECT host_id FROM host_jumps) ORDER BY name`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "jump", Header: "JUMP"},
+			render.Column{Key: "chain", Header: "CHAIN"},
+			render.Column{Key: "proxy_jump", Header: "PROXY_JUMP"},
+		)
+		for rows.Next() {
+			var name, raw string
+			if err := rows.Scan(&name, &raw); err != nil {
+				return err
+			}
+			chain, err := expandJumps(g, name)
+			if err != nil {
+				return err
+			}
+			out.Add(name, strings.Join(g[name], ","), strings.Join(chain, " -> "), raw)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return writeRows(out)
+	},
+}
+
+func init() {
+	jumpCmd.AddCommand(jumpSetCmd, jumpClearCmd, jumpLsCmd)
+	rootCmd.AddCommand(jumpCmd)
+}
+
+// loadJumpGraph 返回 主机名 -> 直接配置的跳板机名（按顺序）
+func loadJumpGraph() (map[string][]string, error) {
+	rows, err := DB.Query(`
+SELECT h.name, j.name
+FROM host_jumps hj
+JOIN hosts h ON h.id=hj.host_id
+JOIN hosts j ON j.id=hj.jump_id
+ORDER BY hj.host_id, hj.seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	g := map[string][]string{}
+	for rows.Next() {
+		var h, j string
+		if err := rows.Scan(&h, &j); err != nil {
+			return nil, err
+		}
+		g[h] = append(g[h], j)
+	}
+	return g, rows.Err()
+}
+
+// expandJumps 展开完整链路：每个跳板机先接上它自己的链路，再接它本身；
+// 重复的跳板只保留第一次出现。链路里出现环时报错。
+func expandJumps(g map[string][]string, name string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	var stack []string
+
+	var walk func(n string) error
+	walk = func(n string) error {
+		for i, s := range stack {
+			if s == n {
+				return fmt.Errorf("jump cycle: %s", strings.Join(append(stack[i:], n), " -> "))
+			}
+		}
+		stack = append(stack, n)
+		for _, hop := range g[n] {
+			if err := walk(hop); err != nil {
+				return err
+			}
+			if !seen[hop] {
+				seen[hop] = true
+				out = append(out, hop)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		return nil
+	}
+	if err := walk(name); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkJumps 校验把 name 的链路设为 hops 后：跳板机都存在，且不会形成环
+func checkJumps(name string, hops []string) error {
+	for _, hop := range hops {
+		if _, err := hostID(hop); err != nil {
+			return fmt.Errorf("jump host %w", err)
+		}
+	}
+	g, err := loadJumpGraph()
+	if err != nil {
+		return err
+	}
+	g[name] = hops
+	_, err = expandJumps(g, name)
+	return err
+}
+
+// setHostJumps 在一个事务里整体替换链路，clearProxyJump 时同时清掉原始 ProxyJump；
+// 调用前先 checkJumps
+func setHostJumps(id int64, hops []string, clearProxyJump bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM host_jumps WHERE host_id=?`, id); err != nil {
+		return err
+	}
+	for i, hop := range hops {
+		if _, err := tx.Exec(`
+INSERT INTO host_jumps(host_id,seq,jump_id)
+SELECT ?,?,id FROM hosts WHERE name=?`, id, i, hop); err != nil {
+			return err
+		}
+	}
+	if clearProxyJump {
+		if _, err := tx.Exec(`UPDATE hosts SET proxy_jump='' WHERE id=?`, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// jumpChain 返回连接 name 要依次经过的跳板机（已展开）
+func jumpChain(name string) ([]string, error) {
+	g, err := loadJumpGraph()
+	if err != nil {
+		return nil, err
+	}
+	return expandJumps(g, name)
+}
+
+// loadJumpHosts 按 jumpChain 加载每个跳板机的连接信息
+func loadJumpHosts(name string) ([]sshHost, error) {
+	chain, err := jumpChain(name)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]sshHost, 0, len(chain))
+	for _, n := range chain {
+		h, err := loadSSHHost(n)
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s: %w", n, err)
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// jumpSpec 生成 ssh -J 的参数；没有链路时用原始 ProxyJump
+func (h sshHost) jumpSpec() string {
+	if len(h.Jumps) == 0 {
+		return h.ProxyJump
+	}
+	hops := make([]string, len(h.Jumps))
+	for i, j := range h.Jumps {
+		hops[i] = jumpHop(j.User, j.Host, j.Port)
+	}
+	return strings.Join(hops, ",")
+}
+
+// jumpHop 格式化 -J 的一跳；IPv6 地址要加方括号，否则和端口分不开
+func jumpHop(user, host string, port int) string {
+	if port != 22 {
+		return user + "@" + net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	if strings.Contains(host, ":") {
+		return user + "@[" + host + "]"
+	}
+	return user + "@" + host
+}
+
+// hopURI 把 -J 格式的一跳（[user@]host[:port]，IPv6 可带方括号）转成 ssh:// URI
+func hopURI(hop string) string {
+	if strings.HasPrefix(hop, "ssh://") {
+		return hop
+	}
+	user := ""
+	if i := strings.LastIndex(hop, "@"); i >= 0 {
+		user, hop = hop[:i+1], hop[i+1:]
+	}
+	host, port, err := net.SplitHostPort(hop)
+	if err != nil {
+		// 没有端口：host、[v6] 或裸 IPv6 地址
+		host, port = strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]"), ""
+	}
+	if port == "" {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return "ssh://" + user + host
+	}
+	return "ssh://" + user + net.JoinHostPort(host, port)
+}
+
+// jumpUsers 返回把主机 id 当跳板机的主机名
+func jumpUsers(id int64) ([]string, error) {
+	rows, err := DB.Query(`
+SELECT DISTINCT h.name FROM host_jumps hj JOIN hosts h ON h.id=hj.host_id
+WHERE hj.jump_id=? ORDER BY h.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
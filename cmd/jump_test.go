@@ -0,0 +1,55 @@
+package cmd
+
+import "testing"
+
+func TestJumpClearDropsProxyJump(t *testing.T) {
+	testDB(t)
+	testHost(t, "bastion")
+	id := testHost(t, "lab")
+	// import ssh-config 进来的主机只有原始 ProxyJump
+	if _, err := DB.Exec(`UPDATE hosts SET proxy_jump='gw.example' WHERE id=?`, id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := jumpSetCmd.RunE(jumpSetCmd, []string{"lab", "bastion"}); err != nil {
+		t.Fatal(err)
+	}
+	h, err := loadSSHHostFull("lab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.jumpSpec(); got != "u@bastion.local" || h.ProxyJump != "" {
+		t.Errorf("after set: jumpSpec = %q, proxy_jump = %q", got, h.ProxyJump)
+	}
+
+	if _, err := DB.Exec(`UPDATE hosts SET proxy_jump='gw.example' WHERE id=?`, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := jumpClearCmd.RunE(jumpClearCmd, []string{"lab"}); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = loadSSHHostFull("lab"); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.jumpSpec(); got != "" {
+		t.Errorf("after clear: jumpSpec = %q, want a direct connection", got)
+	}
+}
+
+func TestJumpHop(t *testing.T) {
+	tests := []struct {
+		user, host string
+		port       int
+		want       string
+	}{
+		{"u", "bastion", 22, "u@bastion"},
+		{"u", "bastion", 2222, "u@bastion:2222"},
+		{"u", "fd00::1", 22, "u@[fd00::1]"},
+		{"u", "fd00::1", 2222, "u@[fd00::1]:2222"},
+	}
+	for _, tt := range tests {
+		if got := jumpHop(tt.user, tt.host, tt.port); got != tt.want {
+			t.Errorf("jumpHop(%q, %q, %d) = %q, want %q", tt.user, tt.host, tt.port, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/render"
+	"sshmgr/internal/sshclient"
+	"sshmgr/internal/sshutil"
+)
+
+var (
+	keyGenType    string
+	keyGenComment string
+	keyGenPath    string
+	keyUse        string
+)
+
+// keyRow 是 ssh_keys 里的一条登录密钥
+type keyRow struct {
+	ID   int64
+	Name string
+	sshutil.UserKey
+}
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Generate login keys and install or rotate them on hosts",
+}
+
+var keyGenCmd = &cobra.Command{
+	Use:   "gen <key>",
+	Short: "Generate a key pair (default ~/.ssh/sshmgr_<key>, no passphrase)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		var exists int
+		_ = DB.QueryRow(`SELECT 1 FROM ssh_keys WHERE name=?`, name).Scan(&exists)
+		if exists == 1 {
+			return fmt.Errorf("key already exists: %s", name)
+		}
+
+		path := keyGenPath
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			path = filepath.Join(home, ".ssh", "sshmgr_"+name)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		comment := keyGenComment
+		if comment == "" {
+			comment = "sshmgr:" + name
+		}
+
+		k, err := sshutil.GenerateKey(path, keyGenType, comment)
+		if err != nil {
+			return err
+		}
+		if _, err := DB.Exec(`
+INSERT INTO ssh_keys(name,path,key_type,fingerprint,public_key,created_at) VALUES(?,?,?,?,?,?)`,
+			name, k.Path, k.Type, k.Fingerprint, k.PublicKey, db.NowUTC()); err != nil {
+			return err
+		}
+		fmt.Printf("generated %s %s\n  %s\n", k.Type, k.Fingerprint, k.Path)
+		return nil
+	},
+}
+
+var keyLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List login keys and how many hosts use each",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rows, err := DB.Query(`
+SELECT k.name,k.key_type,k.fingerprint,k.path,COUNT(hk.host_id),k.created_at
+FROM ssh_keys k LEFT JOIN host_ssh_keys hk ON hk.key_id=k.id
+GROUP BY k.id ORDER BY k.name`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "type", Header: "TYPE"},
+			render.Column{Key: "fingerprint", Header: "FINGERPRINT"},
+			render.Column{Key: "path", Header: "PATH"},
+			render.Column{Key: "hosts", Header: "HOSTS"},
+			render.Column{Key: "created_at", Header: "CREATED", Format: localTimeFmt("2006-01-02 15:04:05")},
+		)
+		for rows.Next() {
+			var name, kt, fp, path, created string
+			var hosts int
+			if err := rows.Scan(&name, &kt, &fp, &path, &hosts, &created); err != nil {
+				return err
+			}
+			out.Add(name, kt, fp, path, hosts, timeValue(created))
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return writeRows(out)
+	},
+}
+
+var keyRmCmd = &cobra.Command{
+	Use:   "rm <key>",
+	Short: "Forget a key (the key files are kept)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		k, err := loadKey(args[0])
+		if err != nil {
+			return err
+		}
+		users, err := keyUsers(k.ID)
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			return fmt.Errorf("key %s is used by: %s (rotate them first)", k.Name, strings.Join(users, ", "))
+		}
+		_, err = DB.Exec(`DELETE FROM ssh_keys WHERE id=?`, k.ID)
+		return err
+	},
+}
+
+var keyPushCmd = &cobra.Command{
+	Use:   "push [name...|all] [--select expr] [--key k]",
+	Short: "Install a key into authorized_keys (like ssh-copy-id, uses the stored password)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runKeyHosts(args, "push", pushKey)
+	},
+}
+
+var keyRotateCmd = &cobra.Command{
+	Use:   "rotate [name...|all] [--select expr] --key <new>",
+	Short: "Install a new key, verify login with it, then remove the old key",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runKeyHosts(args, "rotate", rotateKey)
+	},
+}
+
+func init() {
+	keyGenCmd.Flags().StringVar(&keyGenType, "type", "ed25519", "key type: ed25519|ecdsa|rsa")
+	keyGenCmd.Flags().StringVar(&keyGenComment, "comment", "", "key comment (default sshmgr:<key>)")
+	keyGenCmd.Flags().StringVar(&keyGenPath, "path", "", "private key path (default ~/.ssh/sshmgr_<key>)")
+	for _, c := range []*cobra.Command{keyPushCmd, keyRotateCmd} {
+		c.Flags().StringVar(&keyUse, "key", "", "key to install (may be omitted when only one key exists)")
+		selectFlag(c)
+	}
+	keyCmd.AddCommand(keyGenCmd, keyLsCmd, keyRmCmd, keyPushCmd, keyRotateCmd)
+	rootCmd.AddCommand(keyCmd)
+}
+
+func loadKey(name string) (keyRow, error) {
+	var k keyRow
+	err := DB.QueryRow(`SELECT id,name,path,key_type,fingerprint,public_key FROM ssh_keys WHERE name=?`, name).
+		Scan(&k.ID, &k.Name, &k.Path, &k.Type, &k.Fingerprint, &k.PublicKey)
+	if err == sql.ErrNoRows {
+		return k, fmt.Errorf("key not found: %s (see `sshmgr key ls`)", name)
+	}
+	return k, err
+}
+
+// chosenKey 是 --key；只有一把密钥时可以省略
+func chosenKey() (keyRow, error) {
+	if keyUse != "" {
+		return loadKey(keyUse)
+	}
+	var names []string
+	rows, err := DB.Query(`SELECT name FROM ssh_keys ORDER BY name`)
+	if err != nil {
+		return keyRow{}, err
+	}
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			rows.Close()
+			return keyRow{}, err
+		}
+		names = append(names, n)
+	}
+	rows.Close()
+	switch len(names) {
+	case 0:
+		return keyRow{}, fmt.Errorf("no keys yet, run `sshmgr key gen <key>` first")
+	case 1:
+		return loadKey(names[0])
+	}
+	return keyRow{}, fmt.Errorf("several keys exist (%s), choose one with --key", strings.Join(names, ", "))
+}
+
+// hostKeyName 返回主机当前使用的 sshmgr 密钥名，没有时为空
+func hostKeyName(hostID int64) string {
+	var name string
+	_ = DB.QueryRow(`
+SELECT k.name FROM host_ssh_keys hk JOIN ssh_keys k ON k.id=hk.key_id WHERE hk.host_id=?`, hostID).Scan(&name)
+	return name
+}
+
+func keyUsers(keyID int64) ([]string, error) {
+	rows, err := DB.Query(`
+SELECT h.name FROM host_ssh_keys hk JOIN hosts h ON h.id=hk.host_id WHERE hk.key_id=? ORDER BY h.name`, keyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var n string
+		if err := rows.Scan(&n); err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// runKeyHosts 对选中的主机逐台执行 fn（可能要输入密码，不并发），最后汇总
+func runKeyHosts(names []string, op string, fn func(sshHost, keyRow) error) error {
+	if len(names) == 0 && selectExpr == "" {
+		return fmt.Errorf("select hosts by name, 'all' or --select")
+	}
+	k, err := chosenKey()
+	if err != nil {
+		return err
+	}
+	hosts, err := selectExecHosts(names)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		fmt.Println("no hosts")
+		return nil
+	}
+
+	out := render.New(
+		render.Column{Key: "name", Header: "NAME"},
+		render.Column{Key: "key", Header: "KEY"},
+		render.Column{Key: "status", Header: "ST"},
+		render.Column{Key: "error", Header: "ERROR"},
+	)
+	fail := 0
+	for _, h := range hosts {
+		st, errText := "OK", ""
+		if err := fn(h, k); err != nil {
+			st, errText = "FAIL", err.Error()
+			fail++
+		}
+		out.Add(h.Name, k.Name, st, errText)
+	}
+	if err := writeRows(out); err != nil {
+		return err
+	}
+	if fail > 0 {
+		return fmt.Errorf("key %s failed on %d/%d host(s)", op, fail, len(hosts))
+	}
+	return nil
+}
+
+// 远端脚本都经 sh -c '...' 执行（登录 shell 可能不是 sh），脚本里不能有单引号。
+// 公钥 / 公钥的 base64 部分从 stdin 读，不拼进命令行。
+const (
+	addKeyScript = `umask 077
+mkdir -p ~/.ssh || exit 1
+f=~/.ssh/authorized_keys
+read -r k || exit 1
+b=$(printf "%s\n" "$k" | awk "{print \$2}")
+touch "$f" || exit 1
+awk -v b="$b" "{for(i=1;i<=NF;i++) if(\$i==b) found=1} END{exit !found}" "$f" && exit 0
+[ -s "$f" ] && [ -n "$(tail -c1 "$f")" ] && echo >> "$f"
+printf "%s\n" "$k" >> "$f"`
+
+	removeKeyScript = `umask 077
+f=~/.ssh/authorized_keys
+read -r b || exit 1
+[ -f "$f" ] || exit 0
+awk -v b="$b" "{for(i=1;i<=NF;i++) if(\$i==b) next} {print}" "$f" > "$f.sshmgr" || exit 1
+cat "$f.sshmgr" > "$f" && rm -f "$f.sshmgr"`
+)
+
+func runRemoteScript(c *sshclient.Client, script, input string) error {
+	var stderr bytes.Buffer
+	code, err := c.Run("sh -c '"+script+"'", nil, strings.NewReader(input+"\n"), nil, &stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("remote script exited %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// keySession 解析主机并准备内置客户端会话（跳板机照常认证）
+func keySession(h sshHost) (sshTarget, nativeSession, error) {
+	t, err := resolveSSHTarget(h, "hostname,last-ip", "")
+	if err != nil {
+		return t, nativeSession{}, err
+	}
+	s, err := newNativeSession(h, t, 10*time.Second, true)
+	return t, s, err
+}
+
+// dialWithKey 只用 k 登录目标主机（不用 agent 和密码），用来确认 k 可用
+func dialWithKey(s nativeSession, k keyRow) (*sshclient.Client, error) {
+	targets := append([]sshclient.Target(nil), s.Targets...)
+	last := &targets[len(targets)-1]
+	last.IdentityFiles = []string{k.Path}
+	last.Password = nil
+	c, err := sshclient.Dial(s.Config, targets...)
+	if err != nil {
+		return nil, fmt.Errorf("login with key %s failed: %w", k.Name, err)
+	}
+	return c, nil
+}
+
+// useKey 记录主机改用 k，并把 IdentityFile 指向它
+func useKey(h sshHost, k keyRow) error {
+	if _, err := DB.Exec(`
+INSERT INTO host_ssh_keys(host_id,key_id,installed_at) VALUES(?,?,?)
+ON CONFLICT(host_id) DO UPDATE SET key_id=excluded.key_id, installed_at=excluded.installed_at`,
+		h.ID, k.ID, db.NowUTC()); err != nil {
+		return err
+	}
+	return setHostOption(h.ID, "IdentityFile", k.Path)
+}
+
+func pushKey(h sshHost, k keyRow) error {
+	t, s, err := keySession(h)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = func() error {
+		c, err := sshclient.Dial(s.Config, s.Targets...)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if err := runRemoteScript(c, addKeyScript, k.PublicKey); err != nil {
+			return err
+		}
+
+		v, err := dialWithKey(s, k)
+		if err != nil {
+			return err
+		}
+		_ = v.Close()
+		return useKey(h, k)
+	}()
+	logConn(h.ID, start, time.Now(), t, exitCodeForKey(err), "key push "+k.Name)
+	return err
+}
+
+// rotateKey：用现有凭据装上新密钥，确认新密钥能登录后，用新密钥的连接删掉旧密钥。
+// 旧密钥是主机记录的 sshmgr 密钥，没有时取 IdentityFile 对应的公钥。
+func rotateKey(h sshHost, k keyRow) error {
+	oldName, oldBlob := hostKeyName(h.ID), ""
+	if oldName != "" {
+		old, err := loadKey(oldName)
+		if err != nil {
+			return err
+		}
+		oldBlob = sshutil.KeyBlob(old.PublicKey)
+	} else {
+		path, err := identityFile(h.ID)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			return fmt.Errorf("%s has no known key to rotate, use `sshmgr key push`", h.Name)
+		}
+		old, err := sshutil.LoadKey(strings.Trim(path, `"`))
+		if err != nil {
+			return err
+		}
+		oldName, oldBlob = path, sshutil.KeyBlob(old.PublicKey)
+	}
+	if oldBlob == sshutil.KeyBlob(k.PublicKey) {
+		return fmt.Errorf("%s already uses key %s", h.Name, k.Name)
+	}
+
+	t, s, err := keySession(h)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = func() error {
+		c, err := sshclient.Dial(s.Config, s.Targets...)
+		if err != nil {
+			return err
+		}
+		err = runRemoteScript(c, addKeyScript, k.PublicKey)
+		_ = c.Close()
+		if err != nil {
+			return err
+		}
+
+		v, err := dialWithKey(s, k)
+		if err != nil {
+			return fmt.Errorf("%w (old key %s left in place)", err, oldName)
+		}
+		defer v.Close()
+		if err := useKey(h, k); err != nil {
+			return err
+		}
+		if err := runRemoteScript(v, removeKeyScript, oldBlob); err != nil {
+			return fmt.Errorf("new key installed, but removing old key %s failed: %w", oldName, err)
+		}
+		return nil
+	}()
+	logConn(h.ID, start, time.Now(), t, exitCodeForKey(err), "key rotate "+k.Name)
+	return err
+}
+
+// exitCodeForKey 给 conn_log 用：成功 0，连接/认证失败按 ssh 的习惯记 255
+func exitCodeForKey(err error) int {
+	if err == nil {
+		return 0
+	}
+	return 255
+}
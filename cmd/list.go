@@ -2,26 +2,35 @@ package cmd
 
 import (
 	"database/sql"
-	"fmt"
-	"os"
-	"text/tabwriter"
-	"time"
 
 	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all host entries",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		rows, err := DB.Query(`SELECT name,user,host,port,last_ip,last_checked_at,has_secret FROM hosts ORDER BY name`)
+		cond, condArgs, err := selectSQL("h")
+		if err != nil {
+			return err
+		}
+		rows, err := DB.Query(`SELECT name,user,host,port,last_ip,last_checked_at,has_secret FROM hosts h WHERE `+cond+` ORDER BY name`, condArgs...)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tUSER\tHOST\tPORT\tLAST_IP\tLAST_CHECKED\tHAS_PASSWORD")
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "user", Header: "USER"},
+			render.Column{Key: "host", Header: "HOST"},
+			render.Column{Key: "port", Header: "PORT"},
+			render.Column{Key: "last_ip", Header: "LAST_IP"},
+			render.Column{Key: "last_checked_at", Header: "LAST_CHECKED", Format: localTimeFmt("2006-01-02 15:04:05")},
+			render.Column{Key: "has_password", Header: "HAS_PASSWORD", Format: yesNo},
+		)
 
 		for rows.Next() {
 			var name, user, host string
@@ -33,25 +42,16 @@ var listCmd = &cobra.Command{
 				return err
 			}
 
-			checked := ""
-			if lastChecked.Valid && lastChecked.String != "" {
-				if t, e := time.Parse(time.RFC3339, lastChecked.String); e == nil {
-					checked = t.Local().Format("2006-01-02 15:04:05")
-				} else {
-					checked = lastChecked.String
-				}
-			}
-
-			has := "no"
-			if hasSecret != 0 {
-				has = "yes"
-			}
-
-			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
-				name, user, host, port, lastIP.String, checked, has)
+			out.Add(name, user, host, port, lastIP.String, timeValue(lastChecked.String), hasSecret != 0)
+		}
+		if err := rows.Err(); err != nil {
+			return err
 		}
 
-		_ = w.Flush()
-		return rows.Err()
+		return writeRows(out)
 	},
 }
+
+func init() {
+	selectFlag(listCmd)
+}
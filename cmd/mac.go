@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/neigh"
+)
+
+// readNeighbors 读邻居表；读不到（没有权限、没有 arp 命令）当作空表
+func readNeighbors() []neigh.Entry {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	list, err := neigh.Read(ctx)
+	if err != nil {
+		return nil
+	}
+	return list
+}
+
+// rememberMAC 记下 ip 在邻居表里的 MAC（只有同一链路上的主机才有）。
+// 调用方要先确认 ip 上确实是这台主机（host key 一致）。
+func rememberMAC(hostID int64, ip string) {
+	e, ok := neigh.ByIP(readNeighbors(), ip)
+	if !ok {
+		return
+	}
+	now := db.NowUTC()
+	_, _ = DB.Exec(`
+INSERT INTO host_macs(host_id,mac,last_ip,first_seen_at,last_seen_at) VALUES(?,?,?,?,?)
+ON CONFLICT(host_id,mac) DO UPDATE SET last_ip=excluded.last_ip, last_seen_at=excluded.last_seen_at`,
+		hostID, e.MAC, ip, now, now)
+}
+
+func hostMACs(hostID int64) ([]string, error) {
+	rows, err := DB.Query(`SELECT mac FROM host_macs WHERE host_id=? ORDER BY last_seen_at DESC`, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var macs []string
+	for rows.Next() {
+		var m string
+		if err := rows.Scan(&m); err != nil {
+			return nil, err
+		}
+		macs = append(macs, m)
+	}
+	return macs, rows.Err()
+}
+
+// macOwner 返回记录过这个 MAC 的主机名（最近看到的那台）
+func macOwner(mac string) string {
+	var name string
+	err := DB.QueryRow(`
+SELECT h.name FROM host_macs m JOIN hosts h ON h.id=m.host_id
+WHERE m.mac=? ORDER BY m.last_seen_at DESC LIMIT 1`, mac).Scan(&name)
+	if err == sql.ErrNoRows {
+		return ""
+	}
+	return name
+}
+
+// findByMAC 在邻居表里找这台主机已知 MAC 现在对应的 IP，并用 host key 确认；
+// 没有已知 MAC、邻居表里没有或确认失败时返回空串。不做任何扫描。
+func findByMAC(hostID int64, user, host string, port int, fps map[string]bool) string {
+	if len(fps) == 0 {
+		return "" // 没有 host key 可确认，不为此登录
+	}
+	macs, err := hostMACs(hostID)
+	if err != nil || len(macs) == 0 {
+		return ""
+	}
+	for _, e := range neigh.ByMAC(readNeighbors(), macs) {
+		ip := e.IP.String()
+		if matchHost(ip, user, host, port, fps) {
+			return ip
+		}
+	}
+	return ""
+}
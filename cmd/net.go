@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/neigh"
+	"sshmgr/internal/netif"
+	"sshmgr/internal/render"
+)
+
+// scan / reassociate / discover 不给目标时用本机所在的局域网
+var (
+	netIfaces []string
+	netIPv6   bool
+)
+
+func localNetFlags(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().StringSliceVar(&netIfaces, "iface", nil, "use these interfaces instead of the auto-detected LANs (see `sshmgr net ifaces`)")
+		c.Flags().BoolVar(&netIPv6, "ipv6", false, "also use the IPv6 prefixes of local interfaces")
+	}
+}
+
+func netPolicy() netif.Policy {
+	return netif.Policy{Ifaces: netIfaces, IPv6: netIPv6}
+}
+
+// localSubnets 返回要扫描的本地网段，并在 stderr 上说明用了哪些
+func localSubnets() ([]string, error) {
+	nets, err := netif.Subnets(netPolicy())
+	if err != nil {
+		return nil, err
+	}
+	if len(nets) == 0 {
+		return nil, errors.New("no local LAN found to scan; pass targets or --iface (see `sshmgr net ifaces`)")
+	}
+	var subnets, desc []string
+	for _, n := range nets {
+		subnets = append(subnets, n.Scan.String())
+		desc = append(desc, n.Scan.String()+" ("+n.Iface+")")
+	}
+	fmt.Fprintf(os.Stderr, "local networks: %s\n", strings.Join(desc, ", "))
+	return subnets, nil
+}
+
+var netCmd = &cobra.Command{
+	Use:   "net",
+	Short: "Show local network information",
+}
+
+var netIfacesCmd = &cobra.Command{
+	Use:   "ifaces",
+	Short: "List interfaces and the subnets scan/reassociate/discover use by default",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		nets, err := netif.List(netPolicy())
+		if err != nil {
+			return err
+		}
+		out := render.New(
+			render.Column{Key: "iface", Header: "IFACE"},
+			render.Column{Key: "addr", Header: "ADDR"},
+			render.Column{Key: "prefix", Header: "PREFIX"},
+			render.Column{Key: "scan", Header: "SCAN"},
+			render.Column{Key: "skip", Header: "SKIPPED"},
+		)
+		for _, n := range nets {
+			addr, prefix, scan := "", "", ""
+			if n.Addr.IsValid() {
+				addr, prefix = n.Addr.String(), n.Prefix.String()
+			}
+			if n.Skip == "" {
+				scan = n.Scan.String()
+			}
+			out.Add(n.Iface, addr, prefix, scan, n.Skip)
+		}
+		return writeRows(out)
+	},
+}
+
+var netNeighCmd = &cobra.Command{
+	Use:   "neigh",
+	Short: "Show the ARP/neighbor table and which hosts the MAC addresses belong to",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		list, err := neigh.Read(ctx)
+		if err != nil {
+			return err
+		}
+		out := render.New(
+			render.Column{Key: "ip", Header: "IP"},
+			render.Column{Key: "mac", Header: "MAC"},
+			render.Column{Key: "iface", Header: "IFACE"},
+			render.Column{Key: "host", Header: "HOST"},
+		)
+		for _, e := range list {
+			out.Add(e.IP.String(), e.MAC, e.Iface, macOwner(e.MAC))
+		}
+		return writeRows(out)
+	},
+}
+
+func init() {
+	netCmd.AddCommand(netIfacesCmd, netNeighCmd)
+	localNetFlags(netIfacesCmd)
+	rootCmd.AddCommand(netCmd)
+}
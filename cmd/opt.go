@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
+	"sshmgr/internal/sshconfig"
+)
+
+var optGlobal bool
+
+var optCmd = &cobra.Command{
+	Use:   "opt",
+	Short: "Manage per-host ssh options (and global defaults with --global)",
+}
+
+var optSetCmd = &cobra.Command{
+	Use:   "set <name> <Option> <value...> | --global <Option> <value...>",
+	Short: "Set an ssh_config option, e.g. `opt set mini ServerAliveInterval 30`",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, rest, err := optTarget(args)
+		if err != nil {
+			return err
+		}
+		if len(rest) < 2 {
+			return fmt.Errorf("missing value for %s", rest[0])
+		}
+		key, err := sshconfig.CanonicalKey(rest[0])
+		if err != nil {
+			return err
+		}
+		value := strings.Join(rest[1:], " ")
+
+		if optGlobal {
+			_, err = DB.Exec(`
+INSERT INTO default_options(key,value) VALUES(?,?)
+ON CONFLICT(key) DO UPDATE SET key=excluded.key, value=excluded.value`, key, value)
+		} else {
+			err = setHostOption(id, key, value)
+		}
+		return err
+	},
+}
+
+var optUnsetCmd = &cobra.Command{
+	Use:   "unset <name> <Option> | --global <Option>",
+	Short: "Remove an ssh option",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, rest, err := optTarget(args)
+		if err != nil {
+			return err
+		}
+		if len(rest) != 1 {
+			return fmt.Errorf("give exactly one option name")
+		}
+
+		q, qArgs := `DELETE FROM host_options WHERE host_id=? AND key=?`, []any{id, rest[0]}
+		if optGlobal {
+			q, qArgs = `DELETE FROM default_options WHERE key=?`, []any{rest[0]}
+		}
+		res, err := DB.Exec(q, qArgs...)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("option not set: %s", rest[0])
+		}
+		return nil
+	},
+}
+
+var optLsCmd = &cobra.Command{
+	Use:   "ls <name> | --global",
+	Short: "List effective ssh options of a host (host values override --global defaults)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !optGlobal && len(args) == 0 {
+			return fmt.Errorf("give a host name or --global")
+		}
+
+		defaults, err := defaultOptions()
+		if err != nil {
+			return err
+		}
+		var own []sshconfig.Option
+		if !optGlobal {
+			id, err := hostID(args[0])
+			if err != nil {
+				return err
+			}
+			if own, err = hostOptions(id); err != nil {
+				return err
+			}
+		}
+
+		fromHost := map[string]bool{}
+		for _, o := range own {
+			fromHost[strings.ToLower(o.Key)] = true
+		}
+		out := render.New(
+			render.Column{Key: "key", Header: "OPTION"},
+			render.Column{Key: "value", Header: "VALUE"},
+			render.Column{Key: "source", Header: "FROM"},
+		)
+		for _, o := range sshconfig.Merge(defaults, own) {
+			src := "global"
+			if fromHost[strings.ToLower(o.Key)] {
+				src = "host"
+			}
+			out.Add(o.Key, o.Value, src)
+		}
+		return writeRows(out)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{optSetCmd, optUnsetCmd, optLsCmd} {
+		c.Flags().BoolVar(&optGlobal, "global", false, "work on the defaults applied to every host")
+	}
+	optCmd.AddCommand(optSetCmd, optUnsetCmd, optLsCmd)
+	rootCmd.AddCommand(optCmd)
+}
+
+// optTarget 拆出主机（--global 时没有主机）和剩余参数
+func optTarget(args []string) (int64, []string, error) {
+	if optGlobal {
+		return 0, args, nil
+	}
+	id, err := hostID(args[0])
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(args) < 2 {
+		return 0, nil, fmt.Errorf("missing option name")
+	}
+	return id, args[1:], nil
+}
+
+func setHostOption(id int64, key, value string) error {
+	_, err := DB.Exec(`
+INSERT INTO host_options(host_id,key,value) VALUES(?,?,?)
+ON CONFLICT(host_id,key) DO UPDATE SET key=excluded.key, value=excluded.value`, id, key, value)
+	return err
+}
+
+func hostOptions(id int64) ([]sshconfig.Option, error) {
+	return queryOptions(`SELECT key,value FROM host_options WHERE host_id=? ORDER BY key`, id)
+}
+
+func defaultOptions() ([]sshconfig.Option, error) {
+	return queryOptions(`SELECT key,value FROM default_options ORDER BY key`)
+}
+
+// effectiveOptions 是全局默认值叠加主机自己的选项
+func effectiveOptions(id int64) ([]sshconfig.Option, error) {
+	defaults, err := defaultOptions()
+	if err != nil {
+		return nil, err
+	}
+	own, err := hostOptions(id)
+	if err != nil {
+		return nil, err
+	}
+	return sshconfig.Merge(defaults, own), nil
+}
+
+func queryOptions(q string, args ...any) ([]sshconfig.Option, error) {
+	rows, err := DB.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []sshconfig.Option
+	for rows.Next() {
+		var o sshconfig.Option
+		if err := rows.Scan(&o.Key, &o.Value); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// identityFile 返回主机自己设置的 IdentityFile（不含全局默认）
+func identityFile(id int64) (string, error) {
+	var v string
+	err := DB.QueryRow(`SELECT value FROM host_options WHERE host_id=? AND key='IdentityFile'`, id).Scan(&v)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return v, err
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"sshmgr/internal/render"
+)
+
+var (
+	outputFlag string
+	outFormat  = render.Table
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", "table", "output format: table|json|yaml|csv|tsv")
+}
+
+func writeRows(r *render.Rows) error { return r.Write(os.Stdout, outFormat) }
+
+func writeRecord(r *render.Rows) error { return r.WriteOne(os.Stdout, outFormat) }
+
+// timeValue 把库里的 RFC3339 文本转成 time.Time；空串为零值，解析失败保留原文。
+func timeValue(s string) any {
+	if s == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	return s
+}
+
+// localTimeFmt 返回表格模式下按本地时区显示时间的格式化函数。
+func localTimeFmt(layout string) func(any) string {
+	return func(v any) string {
+		switch x := v.(type) {
+		case time.Time:
+			if x.IsZero() {
+				return ""
+			}
+			return x.Local().Format(layout)
+		case string:
+			return x
+		}
+		return ""
+	}
+}
+
+func yesNo(v any) string {
+	if b, _ := v.(bool); b {
+		return "yes"
+	}
+	return "no"
+}
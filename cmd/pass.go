@@ -1,9 +1,17 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -11,16 +19,19 @@ import (
 	"sshmgr/internal/sys"
 )
 
-var passTTL int
+var (
+	passTTL       int
+	passClipboard string
+)
 
 var passCmd = &cobra.Command{
 	Use:   "pass",
-	Short: "Manage passwords in Keychain (copy-only, no plaintext by default)",
+	Short: "Manage stored passwords (copy-only, no plaintext by default)",
 }
 
 var passSetCmd = &cobra.Command{
 	Use:   "set <name>",
-	Short: "Set or update password in Keychain",
+	Short: "Set or update password in the secret backend",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
@@ -28,6 +39,10 @@ var passSetCmd = &cobra.Command{
 		if err := DB.QueryRow(`SELECT user FROM hosts WHERE name=?`, name).Scan(&u); err != nil {
 			return err
 		}
+		store, err := secretStore()
+		if err != nil {
+			return err
+		}
 
 		fmt.Fprintf(os.Stderr, "Enter password for %s (%s): ", name, u)
 		b, err := term.ReadPassword(int(os.Stdin.Fd()))
@@ -40,10 +55,10 @@ var passSetCmd = &cobra.Command{
 			return fmt.Errorf("empty password")
 		}
 
-		if err := sys.KeychainSet(name, u, pw); err != nil {
+		if err := store.Set(name, u, pw); err != nil {
 			return err
 		}
-		_, _ = DB.Exec(`UPDATE hosts SET has_secret=1 WHERE name=?`, name)
+		setHasSecret(name, true)
 		return nil
 	},
 }
@@ -58,23 +73,37 @@ var passCopyCmd = &cobra.Command{
 		if err := DB.QueryRow(`SELECT user FROM hosts WHERE name=?`, name).Scan(&u); err != nil {
 			return err
 		}
+		store, err := secretStore()
+		if err != nil {
+			return err
+		}
 
-		pw, err := sys.KeychainGet(name, u)
+		pw, err := store.Get(name, u)
+		if errors.Is(err, sys.ErrSecretNotFound) {
+			setHasSecret(name, false)
+			return fmt.Errorf("no password stored for %s in %s", name, store.Name())
+		}
+		if err != nil {
+			return err
+		}
+		clip, err := sys.NewClipboard(passClipboard)
 		if err != nil {
 			return err
 		}
-		if err := sys.ClipboardCopy(pw); err != nil {
+		if err := clip.Copy(pw); err != nil {
 			return err
 		}
-		_ = sys.ClipboardClearAfter(passTTL)
-		fmt.Println("copied to clipboard")
+		fmt.Printf("copied to clipboard (%s)\n", clip.Name())
+		if passTTL > 0 {
+			return scheduleClipboardWipe(clip, pw, passTTL)
+		}
 		return nil
 	},
 }
 
 var passClearCmd = &cobra.Command{
 	Use:   "clear <name>",
-	Short: "Delete password from Keychain",
+	Short: "Delete password from the secret backend",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
@@ -82,16 +111,145 @@ var passClearCmd = &cobra.Command{
 		if err := DB.QueryRow(`SELECT user FROM hosts WHERE name=?`, name).Scan(&u); err != nil {
 			return err
 		}
-		if err := sys.KeychainDelete(name, u); err != nil {
+		store, err := secretStore()
+		if err != nil {
+			return err
+		}
+		if err := store.Delete(name, u); err != nil && !errors.Is(err, sys.ErrSecretNotFound) {
+			return err
+		}
+		setHasSecret(name, false)
+		fmt.Printf("deleted from %s\n", store.Name())
+		return nil
+	},
+}
+
+var passSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Recompute has_password for every host from the secret backend",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := secretStore()
+		if err != nil {
+			return err
+		}
+
+		rows, err := DB.Query(`SELECT name,user FROM hosts ORDER BY name`)
+		if err != nil {
 			return err
 		}
-		_, _ = DB.Exec(`UPDATE hosts SET has_secret=0 WHERE name=?`, name)
-		fmt.Println("deleted from keychain")
+		type hu struct{ name, user string }
+		var all []hu
+		for rows.Next() {
+			var r hu
+			if err := rows.Scan(&r.name, &r.user); err != nil {
+				rows.Close()
+				return err
+			}
+			all = append(all, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		with := 0
+		for _, r := range all {
+			_, err := store.Get(r.name, r.user)
+			if err != nil && !errors.Is(err, sys.ErrSecretNotFound) {
+				return err
+			}
+			setHasSecret(r.name, err == nil)
+			if err == nil {
+				with++
+			}
+		}
+		fmt.Printf("%d/%d host(s) have a password in %s\n", with, len(all), store.Name())
 		return nil
 	},
 }
 
 func init() {
-	passCmd.AddCommand(passSetCmd, passCopyCmd, passClearCmd)
-	passCopyCmd.Flags().IntVar(&passTTL, "ttl", 0, "clear clipboard after N seconds (0=disable)")
+	passCmd.AddCommand(passSetCmd, passCopyCmd, passClearCmd, passSyncCmd, passWipeClipboardCmd)
+	passCopyCmd.Flags().IntVar(&passTTL, "ttl", 0, "clear clipboard after N seconds, unless something else was copied since (0=disable)")
+	passCopyCmd.Flags().StringVar(&passClipboard, "clipboard", os.Getenv("SSHMGR_CLIPBOARD"),
+		"clipboard: "+strings.Join(sys.ClipboardBackends, "|")+" (env SSHMGR_CLIPBOARD)")
+	passWipeClipboardCmd.Flags().IntVar(&passTTL, "after", 0, "seconds to wait")
+	passWipeClipboardCmd.Flags().StringVar(&passClipboard, "clipboard", "", "clipboard backend")
+}
+
+var passWipeClipboardCmd = &cobra.Command{
+	Use:    "wipe-clipboard",
+	Short:  "Clear the clipboard later if it still holds the copied password (started by pass copy --ttl)",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	// 不打开数据库
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// stdin 上是密码的 SHA-256（hex），密码本身不传给这个进程
+		b, err := io.ReadAll(io.LimitReader(os.Stdin, 128))
+		if err != nil {
+			return err
+		}
+		var sum [sha256.Size]byte
+		if n, err := hex.Decode(sum[:], bytes.TrimSpace(b)); err != nil || n != len(sum) {
+			return errors.New("wipe-clipboard: expected a SHA-256 on stdin")
+		}
+		clip, err := sys.NewClipboard(passClipboard)
+		if err != nil {
+			return err
+		}
+		time.Sleep(time.Duration(passTTL) * time.Second)
+		_, err = sys.ClearIfUnchanged(clip, sum)
+		return err
+	},
+}
+
+// scheduleClipboardWipe 启动一个脱离终端的 sshmgr，ttl 秒后剪贴板里
+// 还是这个密码才清空，用户之后复制的内容不受影响。
+func scheduleClipboardWipe(clip sys.Clipboard, pw string, ttl int) error {
+	if _, err := clip.Read(); errors.Is(err, sys.ErrClipboardUnreadable) {
+		fmt.Fprintf(os.Stderr, "warning: the %s clipboard cannot be read back, so it is not cleared automatically\n", clip.Name())
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	sum := sha256.Sum256([]byte(pw))
+	c := exec.Command(exe, "pass", "wipe-clipboard", "--clipboard", clip.Name(), "--after", strconv.Itoa(ttl))
+	c.Stdin = r
+	sys.Detach(c)
+	if err := c.Start(); err != nil {
+		w.Close()
+		return err
+	}
+	_ = c.Process.Release()
+	// 64 字节，管道缓冲区一定放得下，不用等子进程读
+	_, err = io.WriteString(w, hex.EncodeToString(sum[:]))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("clipboard clears in %ds\n", ttl)
+	return nil
+}
+
+func secretStore() (sys.SecretStore, error) {
+	return sys.NewSecretStore(secretBackend)
+}
+
+func setHasSecret(name string, has bool) {
+	v := 0
+	if has {
+		v = 1
+	}
+	_, _ = DB.Exec(`UPDATE hosts SET has_secret=? WHERE name=?`, v, name)
 }
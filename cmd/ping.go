@@ -1,19 +1,23 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"net"
-	"os"
+	"os/exec"
+	"strings"
 	"sync"
-	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
 
-	"sshmgr/internal/db"
 	"sshmgr/internal/netx"
+	"sshmgr/internal/probe"
+	"sshmgr/internal/render"
+	"sshmgr/internal/sshutil"
 )
 
 var (
@@ -27,6 +31,7 @@ type pingRow struct {
 	Name string
 	Host string
 	Port int
+	Jump string // ssh -J 参数；非空时经跳板机检查
 }
 
 type pingResult struct {
@@ -35,14 +40,16 @@ type pingResult struct {
 	IP   string
 	Port int
 	MS   int64
-	ST   string // OK/DOWN/RESOLVE/ERR
+	ST   string // OK/DOWN/RESOLVE/HOSTKEY/ERR
 	Err  error
+
+	Version string // 服务端版本行，经跳板机时为空
 }
 
 var pingCmd = &cobra.Command{
-	Use:   "ping <name|all>",
+	Use:   "ping <name|all> | --select expr",
 	Short: "Health check: resolve host and test TCP connectivity (default port 22)",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if pingTimeout <= 0 {
 			pingTimeout = 2
@@ -51,7 +58,13 @@ var pingCmd = &cobra.Command{
 			pingConcurrency = 30
 		}
 
-		target := args[0]
+		target := "all"
+		if len(args) == 1 {
+			target = args[0]
+		} else if selectExpr == "" {
+			return fmt.Errorf("give a host name, all or --select")
+		}
+
 		var rows []pingRow
 		var err error
 
@@ -63,23 +76,37 @@ var pingCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		if len(rows) == 0 {
+		if len(rows) == 0 && outFormat == render.Table {
 			fmt.Println("no hosts")
 			return nil
 		}
 
 		results := runPing(rows, time.Duration(pingTimeout)*time.Second, pingConcurrency)
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tHOST\tIP\tPORT\tMS\tST")
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "host", Header: "HOST"},
+			render.Column{Key: "ip", Header: "IP"},
+			render.Column{Key: "port", Header: "PORT"},
+			render.Column{Key: "ms", Header: "MS"},
+			render.Column{Key: "status", Header: "ST"},
+			render.Column{Key: "server_version"},
+			render.Column{Key: "error"},
+		)
 		fail := 0
 		for _, r := range results {
 			if r.ST != "OK" {
 				fail++
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", r.Name, r.Host, r.IP, r.Port, r.MS, r.ST)
+			errText := ""
+			if r.Err != nil {
+				errText = r.Err.Error()
+			}
+			out.Add(r.Name, r.Host, r.IP, r.Port, r.MS, r.ST, r.Version, errText)
+		}
+		if err := writeRows(out); err != nil {
+			return err
 		}
-		_ = w.Flush()
 
 		if pingStrict && fail > 0 {
 			return fmt.Errorf("%d host(s) not healthy", fail)
@@ -92,10 +119,28 @@ func init() {
 	pingCmd.Flags().IntVar(&pingTimeout, "timeout", 2, "timeout seconds per host")
 	pingCmd.Flags().IntVar(&pingConcurrency, "concurrency", 30, "concurrency for ping all")
 	pingCmd.Flags().BoolVar(&pingStrict, "strict", false, "exit non-zero if any host is not OK")
+	selectFlag(pingCmd)
 }
 
+// loadAllHosts 按 --select 过滤（为空时返回全部）
 func loadAllHosts() ([]pingRow, error) {
-	rs, err := DB.Query(`SELECT id,name,host,port FROM hosts ORDER BY name`)
+	cond, condArgs, err := selectSQL("h")
+	if err != nil {
+		return nil, err
+	}
+	return loadPingRows(`WHERE `+cond, condArgs...)
+}
+
+func loadOneHost(name string) ([]pingRow, error) {
+	rows, err := loadPingRows(`WHERE name=?`, name)
+	if err == nil && len(rows) == 0 {
+		err = sql.ErrNoRows
+	}
+	return rows, err
+}
+
+func loadPingRows(where string, args ...any) ([]pingRow, error) {
+	rs, err := DB.Query(`SELECT id,name,host,port,proxy_jump FROM hosts h `+where+` ORDER BY name`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -104,21 +149,24 @@ func loadAllHosts() ([]pingRow, error) {
 	var out []pingRow
 	for rs.Next() {
 		var r pingRow
-		if err := rs.Scan(&r.ID, &r.Name, &r.Host, &r.Port); err != nil {
+		if err := rs.Scan(&r.ID, &r.Name, &r.Host, &r.Port, &r.Jump); err != nil {
 			return nil, err
 		}
 		out = append(out, r)
 	}
-	return out, rs.Err()
-}
-
-func loadOneHost(name string) ([]pingRow, error) {
-	var r pingRow
-	err := DB.QueryRow(`SELECT id,name,host,port FROM hosts WHERE name=?`, name).Scan(&r.ID, &r.Name, &r.Host, &r.Port)
-	if err != nil {
+	if err := rs.Err(); err != nil {
 		return nil, err
 	}
-	return []pingRow{r}, nil
+	rs.Close()
+
+	for i := range out {
+		jumps, err := loadJumpHosts(out[i].Name)
+		if err != nil {
+			return nil, err
+		}
+		out[i].Jump = sshHost{ProxyJump: out[i].Jump, Jumps: jumps}.jumpSpec()
+	}
+	return out, nil
 }
 
 func runPing(rows []pingRow, timeout time.Duration, conc int) []pingResult {
@@ -151,6 +199,9 @@ func runPing(rows []pingRow, timeout time.Duration, conc int) []pingResult {
 
 func pingOne(h pingRow, timeout time.Duration) pingResult {
 	res := pingResult{Name: h.Name, Host: h.Host, Port: h.Port, ST: "ERR"}
+	if h.Jump != "" {
+		return pingViaJump(h, res, timeout)
+	}
 
 	// resolve
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -163,27 +214,113 @@ func pingOne(h pingRow, timeout time.Duration) pingResult {
 	}
 	res.IP = ip
 
-	// 顺便更新 last_ip/last_checked_at
-	_, _ = DB.Exec(`UPDATE hosts SET last_ip=?, last_checked_at=? WHERE id=?`, ip, db.NowUTC(), h.ID)
-
-	// 2) tcp connect
-	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", h.Port))
-	d := net.Dialer{Timeout: timeout}
+	// 2) ssh 握手：TCP、版本和 host key 一次拿到，不登录
+	if hostKeyPolicy != "strict" && hostKeyPolicy != "warn" && hostKeyPolicy != "off" {
+		res.Err = fmt.Errorf("invalid --hostkey-policy: %s (want strict|warn|off)", hostKeyPolicy)
+		return res
+	}
+	pinned, err := loadHostKeys(h.ID)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	var algos []string
+	if hostKeyPolicy != "off" {
+		algos = pinnedKeyAlgorithms(pinned)
+	}
+	r := probe.Probe(context.Background(), net.JoinHostPort(ip, fmt.Sprintf("%d", h.Port)), probe.Options{
+		Timeout:           timeout,
+		NoAuth:            true,
+		HostKeyAlgorithms: algos,
+	})
+	res.MS = r.RTT.Milliseconds()
+	res.Version = r.ServerVersion
 
-	start := time.Now()
-	c, err := d.Dial("tcp", addr)
-	ms := time.Since(start).Milliseconds()
-	res.MS = ms
+	switch {
+	case r.TCP != probe.TCPOpen:
+		res.ST = "DOWN"
+		res.Err = r.Err
+		return res
+	case r.HostKey == nil && r.SSH() && len(algos) > 0:
+		// 是 SSH，但拿不出任何一种已固定类型的 key
+		res.ST = "HOSTKEY"
+		res.Err = r.Err
+		return res
+	case r.HostKey == nil:
+		res.Err = r.Err
+		return res
+	case hostKeyPolicy == "off":
+		// 没校验 host key，不知道这个 IP 上是不是这台主机，不记录
+		res.ST = "OK"
+		return res
+	}
 
+	// host key 变了说明这个 IP 上可能已经是另一台机器
+	hk, err := compareHostKeys(h.ID, h.Name, ip, []sshutil.HostKey{*r.HostKey})
 	if err != nil {
-		res.ST = "DOWN"
 		res.Err = err
 		return res
 	}
-	_ = c.Close()
+	if len(hk.Mismatch) > 0 || hk.Unverified {
+		res.ST = "HOSTKEY"
+		return res
+	}
+	// 确认是这台主机之后才更新 last_ip/last_checked_at
+	_ = recordIP(h.ID, ip, "ping")
+	recordServerVersion(h.ID, r.ServerVersion)
 	res.ST = "OK"
 	return res
 }
 
-// ensure we keep sql imported (some environments complain if unused by build tags)
-var _ sql.NullString
+// pingViaJump 让最后一个跳板机连目标端口（ssh -W），收到 SSH banner 即算 OK。
+// 主机名由跳板机解析，这里不记录 IP，也不校验 host key。
+func pingViaJump(h pingRow, res pingResult, timeout time.Duration) pingResult {
+	hops := strings.Split(h.Jump, ",")
+	last := hops[len(hops)-1]
+	args := []string{
+		"-o", "BatchMode=yes",
+		"-o", fmt.Sprintf("ConnectTimeout=%d", int(timeout.Seconds()+0.5)),
+		"-W", net.JoinHostPort(h.Host, fmt.Sprintf("%d", h.Port)),
+	}
+	if len(hops) > 1 {
+		args = append(args, "-J", strings.Join(hops[:len(hops)-1], ","))
+	}
+	args = append(args, hopURI(last))
+
+	// 每多一跳多给一份超时
+	ctx, cancel := context.WithTimeout(context.Background(), timeout*time.Duration(len(hops)+1))
+	defer cancel()
+
+	c := exec.CommandContext(ctx, "ssh", args...)
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	start := time.Now()
+	if err := c.Start(); err != nil {
+		res.Err = err
+		return res
+	}
+	banner := make([]byte, 4)
+	_, readErr := io.ReadFull(stdout, banner)
+	res.MS = time.Since(start).Milliseconds()
+	cancel()
+	_ = c.Wait()
+
+	if readErr == nil && string(banner) == "SSH-" {
+		res.ST = "OK"
+		return res
+	}
+	res.ST = "DOWN"
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		lines := strings.Split(msg, "\n")
+		res.Err = fmt.Errorf("via %s: %s", last, lines[len(lines)-1])
+	} else if readErr != nil {
+		res.Err = fmt.Errorf("via %s: %v", last, readErr)
+	}
+	return res
+}
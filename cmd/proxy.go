@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/netx"
+)
+
+var proxyCmd = &cobra.Command{
+	Use:    "proxy <name>",
+	Short:  "ProxyCommand helper: resolve host (fallback last_ip) and relay stdin/stdout",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		var (
+			host   string
+			port   int
+			lastIP sql.NullString
+		)
+		if err := DB.QueryRow(`SELECT host,port,last_ip FROM hosts WHERE name=?`, name).
+			Scan(&host, &port, &lastIP); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		ip, err := netx.ResolveHost(ctx, host)
+		cancel()
+		// host key 由 ssh 自己校验，这里没有校验，所以不记录 IP：
+		// 占用了回收 IP 的其他机器不能借此改写 last_ip
+		if err != nil || ip == "" {
+			if lastIP.String == "" {
+				return fmt.Errorf("cannot resolve %s and no last_ip recorded", host)
+			}
+			// 解析失败（mDNS 被拦等）时用上次的 IP
+			fmt.Fprintf(os.Stderr, "sshmgr: cannot resolve %s, using last_ip %s\n", host, lastIP.String)
+			ip = lastIP.String
+		}
+
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), 10*time.Second)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		go func() {
+			_, _ = io.Copy(conn, os.Stdin)
+			if tc, ok := conn.(*net.TCPConn); ok {
+				_ = tc.CloseWrite()
+			}
+		}()
+		// 以服务端关闭为准，保证最后的数据都写回 ssh
+		_, err = io.Copy(os.Stdout, conn)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+}
@@ -2,27 +2,30 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 
-	"sshmgr/internal/db"
 	"sshmgr/internal/netutil"
+	"sshmgr/internal/reassoc"
 	"sshmgr/internal/sshutil"
 )
 
 var (
 	reSubnet      string
+	reExclude     []string
 	reTimeout     time.Duration
 	reConcurrency int
 )
 
 func init() {
-	reassociateCmd.Flags().StringVar(&reSubnet, "subnet", "", "CIDR subnet to scan (required)")
+	reassociateCmd.Flags().StringVar(&reSubnet, "subnet", "", "targets to scan: subnets, ranges, addresses or hostnames, comma separated (default: local LANs)")
+	reassociateCmd.Flags().StringSliceVar(&reExclude, "exclude", nil, "addresses, ranges or subnets to skip")
 	reassociateCmd.Flags().DurationVar(&reTimeout, "timeout", 800*time.Millisecond, "dial timeout")
 	reassociateCmd.Flags().IntVar(&reConcurrency, "concurrency", 32, "scan concurrency")
-	_ = reassociateCmd.MarkFlagRequired("subnet")
+	localNetFlags(reassociateCmd)
 
 	rootCmd.AddCommand(reassociateCmd)
 }
@@ -39,78 +42,186 @@ var reassociateCmd = &cobra.Command{
 			id   int64
 			user string
 			host string
+			port int
 		)
 
 		err := DB.QueryRow(
-			`SELECT id, user, host FROM hosts WHERE name=?`,
+			`SELECT id, user, host, port FROM hosts WHERE name=?`,
 			name,
-		).Scan(&id, &user, &host)
+		).Scan(&id, &user, &host, &port)
 		if err != nil {
 			return fmt.Errorf("host %q not found", name)
 		}
 
-		fmt.Printf("Reassociating %s (%s)...\n", name, host)
+		fps, err := knownFingerprints(id, name)
+		if err != nil {
+			return err
+		}
+		if len(fps) > 0 {
+			fmt.Printf("Reassociating %s by host key (%d fingerprint(s))...\n", name, len(fps))
+		} else {
+			// 没有任何已知 host key：只能退回到登录后跑 hostname
+			fmt.Printf("Reassociating %s (%s) by remote hostname (no known host key)...\n", name, host)
+		}
 
-		// 2. expand subnet
-		ips, err := expandSubnet(reSubnet)
+		// 2. 邻居表里已知 MAC 的当前 IP，不用扫描
+		if ip := findByMAC(id, user, host, port, fps); ip != "" {
+			applyReassociation(id, name, ip, fps)
+			fmt.Printf("Reassociated %s -> %s (by MAC)\n", name, ip)
+			return nil
+		}
+
+		// 3. expand targets
+		subnets := []string{reSubnet}
+		if reSubnet == "" {
+			if subnets, err = localSubnets(); err != nil {
+				return err
+			}
+		}
+		ips, err := expandTargets(subnets, reExclude)
 		if err != nil {
 			return err
 		}
 
-		sem := make(chan struct{}, reConcurrency)
-		found := make(chan string, 1)
+		ip := findOnSubnet(ips, user, host, port, fps, reTimeout, reConcurrency)
+		if ip == "" {
+			fmt.Println("No matching host found")
+			return nil
+		}
 
-		var wg sync.WaitGroup
+		// 4. update last_ip
+		applyReassociation(id, name, ip, fps)
 
-		for _, ip := range ips {
-			ip := ip
-			wg.Add(1)
+		fmt.Printf("Reassociated %s -> %s\n", name, ip)
+		return nil
+	},
+}
 
-			go func() {
-				defer wg.Done()
-				sem <- struct{}{}
-				defer func() { <-sem }()
+func applyReassociation(id int64, name, ip string, fps map[string]bool) {
+	_ = recordIP(id, ip, "reassociate")
+	if len(fps) > 0 {
+		rememberMAC(id, ip)
+	}
+	if tbl := reassocTable(); tbl != nil {
+		for fp := range fps {
+			tbl.Update(fp, name, ip)
+		}
+		saveReassocTable()
+	}
+}
 
-				// must have SSH
-				if _, err := netutil.SSHBanner(ip, reTimeout); err != nil {
-					return
-				}
+// findOnSubnet 并发探测 ips，返回第一个被 matchHost 认出的 IP；找不到返回空串。
+func findOnSubnet(ips []string, user, host string, port int, fps map[string]bool, timeout time.Duration, concurrency int) string {
+	sem := make(chan struct{}, concurrency)
+	found := make(chan string, 1)
 
-				// authoritative check: remote hostname
-				h, err := sshutil.RemoteHostname(user, ip)
-				if err != nil {
-					return
-				}
+	var wg sync.WaitGroup
 
-				if h == host {
-					select {
-					case found <- ip:
-					default:
-					}
-				}
-			}()
-		}
+	for _, ip := range ips {
+		ip := ip
+		wg.Add(1)
 
 		go func() {
-			wg.Wait()
-			close(found)
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// must have SSH
+			if _, err := netutil.SSHBanner(ip, port, timeout); err != nil {
+				return
+			}
+
+			if matchHost(ip, user, host, port, fps) {
+				select {
+				case found <- ip:
+				default:
+				}
+			}
 		}()
+	}
 
-		ip, ok := <-found
-		if !ok {
-			fmt.Println("No matching host found")
-			return nil
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	// 关闭后读到空串，即未找到
+	return <-found
+}
+
+// matchHost 优先用公钥指纹判断（无需登录）；没有已知指纹时才退回到 ssh 跑 hostname。
+func matchHost(ip, user, host string, port int, fps map[string]bool) bool {
+	if len(fps) == 0 {
+		h, err := sshutil.RemoteHostname(user, ip, port)
+		return err == nil && h == host
+	}
+
+	keys, err := sshutil.HostKeys(ip, port)
+	if err != nil {
+		return false
+	}
+	for _, k := range keys {
+		if fps[k.Fingerprint] {
+			return true
 		}
+	}
+	return false
+}
 
-		// 3. update last_ip
-		_, _ = DB.Exec(
-			`UPDATE hosts SET last_ip=?, last_checked_at=? WHERE id=?`,
-			ip,
-			db.NowUTC(),
-			id,
-		)
+// knownFingerprints 合并 reassoc 表和已固定的 host_keys。
+func knownFingerprints(hostID int64, name string) (map[string]bool, error) {
+	fps := map[string]bool{}
+	if tbl := reassocTable(); tbl != nil {
+		for _, fp := range tbl.Fingerprints(name) {
+			fps[fp] = true
+		}
+	}
+
+	pinned, err := loadHostKeys(hostID)
+	if err != nil {
+		return nil, err
+	}
+	for _, fp := range pinned {
+		fps[fp] = true
+	}
+	return fps, nil
+}
 
-		fmt.Printf("Reassociated %s -> %s\n", name, ip)
-		return nil
-	},
+var (
+	reassocOnce sync.Once
+	reassocTbl  *reassoc.Table
+)
+
+// reassocTable 懒加载 fingerprint -> {name, ip} 表，整个进程共用一份。
+func reassocTable() *reassoc.Table {
+	reassocOnce.Do(func() {
+		t, err := reassoc.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reassoc table: %v\n", err)
+			return
+		}
+		reassocTbl = t
+	})
+	return reassocTbl
+}
+
+func saveReassocTable() {
+	if reassocTbl == nil {
+		return
+	}
+	if err := reassocTbl.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "reassoc table: %v\n", err)
+	}
+}
+
+// rememberHostKeys 把本次看到的 host key 记进 reassoc 表，供之后按指纹找回 IP。
+func rememberHostKeys(name, ip string, keys []sshutil.HostKey) {
+	tbl := reassocTable()
+	if tbl == nil || len(keys) == 0 {
+		return
+	}
+	for _, k := range keys {
+		tbl.Update(k.Fingerprint, name, ip)
+	}
+	saveReassocTable()
 }
@@ -2,17 +2,30 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
 var rmCmd = &cobra.Command{
 	Use:   "rm <name>",
-	Short: "Remove one host entry (does not delete Keychain password)",
+	Short: "Remove one host entry (does not delete stored password)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
-		res, err := DB.Exec(`DELETE FROM hosts WHERE name=?`, name)
+		id, err := hostID(name)
+		if err != nil {
+			return err
+		}
+		users, err := jumpUsers(id)
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			return fmt.Errorf("%s is the jump host of %s, change those first (sshmgr jump set/clear)", name, strings.Join(users, ", "))
+		}
+
+		res, err := DB.Exec(`DELETE FROM hosts WHERE id=?`, id)
 		if err != nil {
 			return err
 		}
@@ -20,6 +33,11 @@ var rmCmd = &cobra.Command{
 		if n == 0 {
 			return fmt.Errorf("not found: %s", name)
 		}
-		return nil
+		// reassoc 表按名字记指纹，不清掉的话以后同名的新主机会继承旧机器的指纹
+		if tbl := reassocTable(); tbl != nil {
+			tbl.Forget(name)
+			saveReassocTable()
+		}
+		return pruneTags()
 	},
 }
@@ -4,12 +4,14 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	_ "modernc.org/sqlite"
 
 	"sshmgr/internal/app"
 	"sshmgr/internal/db"
+	"sshmgr/internal/render"
 )
 
 // 注意：banner 这里不能包含反引号 ` ，否则会把 Go 的 raw string 截断
@@ -29,26 +31,39 @@ var (
 )
 
 var (
-	dbPath string
-	DB     *sql.DB
+	dbPath        string
+	secretBackend string
+	DB            *sql.DB
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "sshmgr",
-	Short: "Manage LAN Mac SSH entries, Keychain passwords, and IP-change hints",
-	Long:  "sshmgr manages SSH targets (recommended host: xxx.local), resolves hostnames before connect, warns on IP changes, and stores passwords in Keychain (copy-only).\n",
+	Short: "Manage LAN Mac SSH entries, stored passwords, and IP-change hints",
+	Long:  "sshmgr manages SSH targets (recommended host: xxx.local), resolves hostnames before connect, warns on IP changes, and stores passwords in Keychain or another secret backend (copy-only).\n",
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		f, err := render.ParseFormat(outputFlag)
+		if err != nil {
+			return err
+		}
+		outFormat = f
+
 		if DB != nil {
 			return nil
 		}
 		if dbPath == "" {
 			dbPath = app.DefaultDBPath()
 		}
+		// 绝对路径：备份文件名、导出的 ProxyCommand 都会用到
+		if abs, err := filepath.Abs(dbPath); err == nil {
+			dbPath = abs
+		}
 		if err := app.EnsureParentDir(dbPath); err != nil {
 			return err
 		}
 
-		d, err := sql.Open("sqlite", dbPath)
+		// tunnel supervisor 等后台进程会同时写库，遇到锁时等一会儿；
+		// 外键要在每个连接上打开，放在 DSN 里
+		d, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
 		if err != nil {
 			return err
 		}
@@ -57,7 +72,13 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 		DB = d
-		return nil
+
+		// db migrate 自己处理 --status/--dry-run，这里不要抢先升级
+		if cmd == dbMigrateCmd {
+			return nil
+		}
+		_, err = migrateWithBackup(d)
+		return err
 	},
 }
 
@@ -70,6 +91,8 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&dbPath, "db", app.DefaultDBPath(), "sqlite db path")
+	rootCmd.PersistentFlags().StringVar(&secretBackend, "secret-backend", os.Getenv("SSHMGR_SECRET_BACKEND"),
+		"password backend: auto|keychain|secret-service|pass|age (env SSHMGR_SECRET_BACKEND)")
 
 	// help 顶部显示 banner
 	tpl := banner + `{{with (or .Long .Short)}}{{. | trimTrailingWhitespaces}}{{end}}
@@ -92,5 +115,5 @@ Usage:
 	rootCmd.Version = fmt.Sprintf("%s\nversion=%s\nauthor=%s\n", banner, version, author)
 	rootCmd.SetVersionTemplate("{{.Version}}")
 
-	rootCmd.AddCommand(addCmd, listCmd, showCmd, rmCmd, checkCmd, sshCmd, usersCmd, historyCmd, passCmd, discoverCmd, pingCmd, stevenCmd)
+	rootCmd.AddCommand(addCmd, listCmd, showCmd, rmCmd, checkCmd, sshCmd, usersCmd, historyCmd, passCmd, discoverCmd, pingCmd, stevenCmd, dbCmd)
 }
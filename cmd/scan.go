@@ -1,43 +1,66 @@
 package cmd
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 
 	"sshmgr/internal/netutil"
+	"sshmgr/internal/probe"
 	"sshmgr/internal/sshutil"
+	"sshmgr/internal/targets"
 )
 
 var (
 	scanTimeout     time.Duration
 	scanConcurrency int
 	scanUser        string
+	scanExclude     []string
+	scanPort        int
 )
 
 func init() {
 	scanCmd.Flags().DurationVar(&scanTimeout, "timeout", 500*time.Millisecond, "dial timeout")
 	scanCmd.Flags().IntVar(&scanConcurrency, "concurrency", 64, "number of workers")
 	scanCmd.Flags().StringVar(&scanUser, "user", "", "user for SSH hostname fallback")
+	scanCmd.Flags().StringSliceVar(&scanExclude, "exclude", nil, "addresses, ranges or subnets to skip")
+	scanCmd.Flags().IntVar(&scanPort, "port", 22, "SSH port to probe")
+	localNetFlags(scanCmd)
 	rootCmd.AddCommand(scanCmd)
 }
 
 var scanCmd = &cobra.Command{
-	Use:   "scan <subnet>",
+	Use:   "scan [target...]",
 	Short: "Scan subnet and detect SSH services",
-	Args:  cobra.ExactArgs(1),
+	Long: `Scan targets for SSH services. A target is a subnet (10.0.0.0/24, fd00::/64),
+a range (10.0.0.5-40), an address or a hostname; several can be given, comma
+separated or as separate arguments, and !target excludes. IPv6 prefixes are
+not swept: their hosts are taken from the neighbor table.
+
+Without targets the LANs of the local interfaces are scanned (see net ifaces).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ips, err := expandSubnet(args[0])
+		if len(args) == 0 {
+			local, err := localSubnets()
+			if err != nil {
+				return err
+			}
+			args = local
+		}
+		ips, err := expandTargets(args, scanExclude)
 		if err != nil {
 			return err
 		}
 
 		fmt.Printf("Scanning %d hosts ...\n", len(ips))
 
+		tbl := reassocTable()
+
 		sem := make(chan struct{}, scanConcurrency)
 		out := make(chan string)
 
@@ -52,13 +75,18 @@ var scanCmd = &cobra.Command{
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				// 1. SSH banner probe
-				banner, err := netutil.SSHBanner(ip, scanTimeout)
-				if err != nil || banner == "" {
+				// 1. SSH 握手（不登录）：版本行和 host key
+				r := probe.Probe(context.Background(), net.JoinHostPort(ip, strconv.Itoa(scanPort)), probe.Options{
+					DialTimeout: scanTimeout,
+					Timeout:     scanTimeout + 2*time.Second,
+					NoAuth:      true,
+				})
+				if !r.SSH() {
 					return
 				}
 
-				hostname := netutil.ExtractHostname(banner)
+				banner, _ := netutil.ParseBanner(r.ServerVersion)
+				hostname := banner.Hostname()
 
 				// 2. reverse DNS fallback
 				if hostname == "" {
@@ -69,7 +97,7 @@ var scanCmd = &cobra.Command{
 
 				// 3. remote hostname via ssh
 				if hostname == "" && scanUser != "" {
-					if h, _ := sshutil.RemoteHostname(scanUser, ip); h != "" {
+					if h, _ := sshutil.RemoteHostname(scanUser, ip, scanPort); h != "" {
 						hostname = h
 					}
 				}
@@ -78,7 +106,24 @@ var scanCmd = &cobra.Command{
 					hostname = "(unknown)"
 				}
 
-				out <- fmt.Sprintf("%s  %s", ip, hostname)
+				// 4. known host by public key fingerprint (no login needed)
+				known := ""
+				if tbl != nil && r.HostKey != nil {
+					if e, ok := tbl.Lookup(r.HostKey.Fingerprint); ok && recordScanned(e.Name, ip, *r.HostKey, r.ServerVersion) {
+						known = e.Name
+						tbl.Update(r.HostKey.Fingerprint, e.Name, ip)
+					}
+				}
+
+				software := banner.Software
+				if banner.Comments != "" {
+					software += " " + banner.Comments
+				}
+				if known != "" {
+					out <- fmt.Sprintf("%s  %s  %s  [%s]", ip, hostname, software, known)
+					return
+				}
+				out <- fmt.Sprintf("%s  %s  %s", ip, hostname, software)
 			}()
 		}
 
@@ -93,30 +138,48 @@ var scanCmd = &cobra.Command{
 			found++
 		}
 
+		saveReassocTable()
+
 		fmt.Printf("\nFound %d SSH host(s)\n", found)
 		return nil
 	},
 }
 
-// expandSubnet expands CIDR like 10.203.9.0/24 into usable host IPs.
-func expandSubnet(cidr string) ([]string, error) {
-	_, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid subnet: %w", err)
+// recordScanned 记下 scan 按指纹认出的主机，和 reassociate 一样更新 IP 和 MAC。
+// reassoc 表可能比 host_keys 旧，所以指纹必须仍是这台主机固定的 key。
+func recordScanned(name, ip string, k sshutil.HostKey, version string) bool {
+	var id int64
+	if err := DB.QueryRow(`SELECT id FROM hosts WHERE name=?`, name).Scan(&id); err != nil {
+		return false
 	}
-
-	base := binary.BigEndian.Uint32(ipnet.IP.To4())
-	mask := binary.BigEndian.Uint32(net.IP(ipnet.Mask).To4())
-
-	start := base & mask
-	end := start | ^mask
-
-	var ips []string
-	for i := start + 1; i < end; i++ {
-		var b [4]byte
-		binary.BigEndian.PutUint32(b[:], i)
-		ips = append(ips, net.IP(b[:]).String())
+	pinned, err := loadHostKeys(id)
+	if err != nil || pinned[k.Type] != k.Fingerprint {
+		return false
 	}
+	_ = recordIP(id, ip, "scan")
+	rememberMAC(id, ip)
+	recordServerVersion(id, version)
+	return true
+}
 
+// expandTargets 展开 scan / reassociate 的目标（见 internal/targets），
+// exclude 里的每一项等同于 !项。
+func expandTargets(specs, exclude []string) ([]string, error) {
+	for _, x := range exclude {
+		for _, f := range strings.FieldsFunc(x, func(r rune) bool { return r == ',' || r == ' ' }) {
+			specs = append(specs, "!"+strings.TrimPrefix(f, "!"))
+		}
+	}
+	ips, err := targets.Expand(context.Background(), specs, targets.Options{})
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		all := strings.Join(specs, ",")
+		if strings.Contains(all, ":") {
+			return nil, fmt.Errorf("no addresses to scan in %s (IPv6 prefixes only cover hosts in the neighbor table)", all)
+		}
+		return nil, fmt.Errorf("no addresses to scan in %s", all)
+	}
 	return ips, nil
 }
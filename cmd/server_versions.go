@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/netutil"
+	"sshmgr/internal/render"
+)
+
+var (
+	versionsOutdated bool
+	versionsMin      string
+	versionsStrict   bool
+)
+
+// opensshAdvisory 是影响服务端的已知上游漏洞：From <= 版本 < Fixed。
+// 发行版常把补丁 backport 到旧版本号上，所以这里只能算提示。
+type opensshAdvisory struct {
+	ID    string
+	Desc  string
+	From  netutil.OpenSSHVersion
+	Fixed netutil.OpenSSHVersion
+}
+
+var opensshAdvisories = []opensshAdvisory{
+	{"CVE-2018-15473", "username enumeration", netutil.OpenSSHVersion{}, netutil.OpenSSHVersion{Major: 7, Minor: 8}},
+	{"CVE-2021-41617", "privilege escalation via AuthorizedKeysCommand", netutil.OpenSSHVersion{Major: 6, Minor: 2}, netutil.OpenSSHVersion{Major: 8, Minor: 8}},
+	{"CVE-2023-48795", "Terrapin prefix truncation", netutil.OpenSSHVersion{}, netutil.OpenSSHVersion{Major: 9, Minor: 6}},
+	{"CVE-2024-6387", "regreSSHion pre-auth RCE", netutil.OpenSSHVersion{Major: 8, Minor: 5}, netutil.OpenSSHVersion{Major: 9, Minor: 8}},
+	{"CVE-2025-26466", "pre-auth CPU/memory DoS", netutil.OpenSSHVersion{Major: 9, Minor: 5}, netutil.OpenSSHVersion{Major: 9, Minor: 9, Patch: 2}},
+	{"CVE-2025-32728", "DisableForwarding ignored for X11/agent", netutil.OpenSSHVersion{Major: 7, Minor: 4}, netutil.OpenSSHVersion{Major: 10, Minor: 0}},
+}
+
+func advisoriesFor(v netutil.OpenSSHVersion) []string {
+	var ids []string
+	for _, a := range opensshAdvisories {
+		if !v.Less(a.From) && v.Less(a.Fixed) {
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids
+}
+
+var versionsCmd = &cobra.Command{
+	Use:   "server-versions [--outdated] [--min version]",
+	Short: "Report the SSH server version of each host and flag outdated OpenSSH",
+	Long: `Report the SSH server software recorded for each host by ping, check and scan
+(only after the host key matched). An OpenSSH release is outdated when it is
+affected by a known server-side advisory, or older than --min.
+
+Distributions often backport fixes without changing the upstream version
+(see the COMMENTS column, e.g. "Ubuntu-3ubuntu0.10"), so treat the result as
+a list of hosts to look at, not as proof of a vulnerability. Hosts with no
+recorded version are UNKNOWN: run sshmgr ping to fill them in.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var minVer netutil.OpenSSHVersion
+		if versionsMin != "" {
+			v, err := netutil.ParseOpenSSHVersion(versionsMin)
+			if err != nil {
+				return err
+			}
+			minVer = v
+		}
+
+		cond, condArgs, err := selectSQL("h")
+		if err != nil {
+			return err
+		}
+		rows, err := DB.Query(`
+SELECT h.name, h.host, h.server_version, h.server_version_at
+FROM hosts h WHERE `+cond+` ORDER BY h.name`, condArgs...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "host", Header: "HOST"},
+			render.Column{Key: "software", Header: "SOFTWARE"},
+			render.Column{Key: "comments", Header: "COMMENTS"},
+			render.Column{Key: "status", Header: "ST"},
+			render.Column{Key: "advisories", Header: "ADVISORIES"},
+			render.Column{Key: "seen_at", Header: "SEEN", Format: localTimeFmt("2006-01-02 15:04")},
+			render.Column{Key: "server_version"},
+		)
+		outdated := 0
+		for rows.Next() {
+			var name, host, raw, seen string
+			if err := rows.Scan(&name, &host, &raw, &seen); err != nil {
+				return err
+			}
+			st, software, comments, advisories := "UNKNOWN", "", "", []string(nil)
+			if b, err := netutil.ParseBanner(raw); err == nil {
+				software, comments = b.Software, b.Comments
+				st = "OTHER" // dropbear、libssh 等
+				if v, ok := b.OpenSSH(); ok {
+					st = "OK"
+					advisories = advisoriesFor(v)
+					if len(advisories) > 0 || v.Less(minVer) {
+						st = "OUTDATED"
+					}
+				}
+			}
+			if st == "OUTDATED" {
+				outdated++
+			} else if versionsOutdated {
+				continue
+			}
+			out.Add(name, host, software, comments, st, strings.Join(advisories, ","), timeValue(seen), raw)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := writeRows(out); err != nil {
+			return err
+		}
+		if versionsStrict && outdated > 0 {
+			return fmt.Errorf("%d host(s) running outdated OpenSSH", outdated)
+		}
+		return nil
+	},
+}
+
+func init() {
+	versionsCmd.Flags().BoolVar(&versionsOutdated, "outdated", false, "only list hosts running outdated OpenSSH")
+	versionsCmd.Flags().StringVar(&versionsMin, "min", "", "also flag OpenSSH releases older than this, e.g. 9.8 or 9.9p2")
+	versionsCmd.Flags().BoolVar(&versionsStrict, "strict", false, "exit non-zero if any host runs outdated OpenSSH")
+	selectFlag(versionsCmd)
+	rootCmd.AddCommand(versionsCmd)
+}
+
+// recordServerVersion 记下主机的服务端版本行；调用方要先确认 host key 一致，
+// 否则记下的可能是占用了这个 IP 的另一台机器。
+func recordServerVersion(hostID int64, version string) {
+	if version == "" {
+		return
+	}
+	_, _ = DB.Exec(`UPDATE hosts SET server_version=?, server_version_at=? WHERE id=?`,
+		strings.TrimRight(version, "\r\n"), db.NowUTC(), hostID)
+}
@@ -1,9 +1,11 @@
 package cmd
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
 )
 
 var showCmd = &cobra.Command{
@@ -13,22 +15,63 @@ var showCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 
-		var user, host, note, tags, lastIP, lastChecked, created string
+		var user, host, note, identity, jump, lastIP, lastChecked, serverVersion, created string
+		var id int64
 		var port, hasSecret int
 
 		err := DB.QueryRow(`
-SELECT user,host,port,note,tags,last_ip,last_checked_at,has_secret,created_at
+SELECT id,user,host,port,note,proxy_jump,last_ip,last_checked_at,server_version,has_secret,created_at
 FROM hosts WHERE name=?`, name).Scan(
-			&user, &host, &port, &note, &tags, &lastIP, &lastChecked, &hasSecret, &created,
+			&id, &user, &host, &port, &note, &jump, &lastIP, &lastChecked, &serverVersion, &hasSecret, &created,
 		)
 		if err != nil {
 			return err
 		}
+		tags, err := hostTags(id)
+		if err != nil {
+			return err
+		}
+		g, err := loadJumpGraph()
+		if err != nil {
+			return err
+		}
+		macs, err := hostMACs(id)
+		if err != nil {
+			return err
+		}
+		opts, err := hostOptions(id)
+		if err != nil {
+			return err
+		}
+		var optText []string
+		for _, o := range opts {
+			if o.Key == "IdentityFile" {
+				identity = o.Value
+				continue
+			}
+			optText = append(optText, o.Key+"="+o.Value)
+		}
 
-		fmt.Printf(
-			"name: %s\nuser: %s\nhost: %s\nport: %d\nnote: %s\ntags: %s\nlast_ip: %s\nlast_checked_at: %s\nhas_password: %v\ncreated_at: %s\n",
-			name, user, host, port, note, tags, lastIP, lastChecked, hasSecret != 0, created,
+		out := render.New(
+			render.Column{Key: "name"},
+			render.Column{Key: "user"},
+			render.Column{Key: "host"},
+			render.Column{Key: "port"},
+			render.Column{Key: "note"},
+			render.Column{Key: "tags"},
+			render.Column{Key: "identity_file"},
+			render.Column{Key: "ssh_key"},
+			render.Column{Key: "jump"},
+			render.Column{Key: "proxy_jump"},
+			render.Column{Key: "options"},
+			render.Column{Key: "last_ip"},
+			render.Column{Key: "mac"},
+			render.Column{Key: "last_checked_at"},
+			render.Column{Key: "server_version"},
+			render.Column{Key: "has_password"},
+			render.Column{Key: "created_at"},
 		)
-		return nil
+		out.Add(name, user, host, port, note, strings.Join(tags, ","), identity, hostKeyName(id), strings.Join(g[name], ","), jump, strings.Join(optText, "; "), lastIP, strings.Join(macs, ","), timeValue(lastChecked), serverVersion, hasSecret != 0, timeValue(created))
+		return writeRecord(out)
 	},
 }
@@ -2,92 +2,105 @@ package cmd
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/user"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"sshmgr/internal/db"
 	"sshmgr/internal/netx"
+	"sshmgr/internal/sshconfig"
 )
 
-var sshDryRun bool
+var (
+	sshDryRun       bool
+	sshResolve      string
+	sshSubnet       string
+	sshWake         bool
+	sshAutoPassword bool
+)
+
+// sshHost 是连接一台主机需要的字段（ssh / exec 共用）
+type sshHost struct {
+	ID        int64
+	Name      string
+	User      string
+	Host      string
+	Port      int
+	ProxyJump string
+	LastIP    string
+	Jumps     []sshHost          // 展开后的跳板机链路，见 loadJumpHosts
+	Options   []sshconfig.Option // 生效的 ssh 选项（含全局默认），见 effectiveOptions
+}
+
+// sshTarget 是解析策略的结果：Addr 是交给 ssh 的地址，Path 记录走的哪条路径。
+type sshTarget struct {
+	Addr string
+	IP   string
+	Path string // hostname / last-ip / reassociate / jump / unresolved
+	// Verified：sshmgr 已确认 Addr 上的 host key 与固定的一致
+	// （keyscan 失败、--hostkey-policy=off、经跳板机或没解析出来时为 false）
+	Verified bool
+}
 
 var sshCmd = &cobra.Command{
 	Use:   "ssh <name>",
 	Short: "Connect to target (resolves host, reports IP changes, writes history)",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
-
-		var (
-			id     int64
-			u      string
-			host   string
-			port   int
-			lastIP sql.NullString
-		)
-		if err := DB.QueryRow(`SELECT id,user,host,port,last_ip FROM hosts WHERE name=?`, name).
-			Scan(&id, &u, &host, &port, &lastIP); err != nil {
+		h, err := loadSSHHostFull(args[0])
+		if err != nil {
 			return err
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-		ip, err := netx.ResolveHost(ctx, host)
-		if err == nil && ip != "" {
-			// 提示变更 + 更新 last_ip
-			if lastIP.Valid && lastIP.String != "" && lastIP.String != ip {
-				fmt.Printf("IP changed: %s -> %s\n", lastIP.String, ip)
+		if sshWake {
+			if err := wakeBeforeSSH(h); err != nil {
+				return err
 			}
-			_, _ = DB.Exec(`UPDATE hosts SET last_ip=?, last_checked_at=? WHERE id=?`, ip, db.NowUTC(), id)
 		}
 
-		target := fmt.Sprintf("%s@%s", u, host)
-		argsSSH := []string{"-p", fmt.Sprintf("%d", port), target}
+		t, err := resolveSSHTarget(h, sshResolve, sshSubnet)
+		if err != nil {
+			return err
+		}
+
+		native, err := useNative()
+		if err != nil {
+			return err
+		}
+		if native {
+			return sshNative(h, t)
+		}
+
+		argsSSH := h.sshArgs(t)
+		if sshAutoPassword {
+			argsSSH = append(autoPasswordArgs(h, t), argsSSH...)
+		}
 
 		if sshDryRun {
-			fmt.Printf("dry-run: ssh %v\n", argsSSH)
+			fmt.Printf("dry-run (%s): ssh %v\n", t.Path, argsSSH)
 			return nil
 		}
 
-		start := time.Now()
 		c := exec.Command("ssh", argsSSH...)
 		c.Stdin = os.Stdin
 		c.Stdout = os.Stdout
 		c.Stderr = os.Stderr
-
-		err = c.Run()
-		end := time.Now()
-
-		exitCode := 0
-		if err != nil {
-			if ee, ok := err.(*exec.ExitError); ok {
-				exitCode = ee.ProcessState.ExitCode()
-			} else {
-				exitCode = -1
+		if sshAutoPassword {
+			env, cleanup, err := askpassEnv(h, t)
+			if err != nil {
+				return err
 			}
+			defer cleanup()
+			c.Env = append(os.Environ(), env...)
 		}
 
-		localUser := ""
-		if me, e := user.Current(); e == nil {
-			localUser = me.Username
-		}
+		start := time.Now()
 
-		_, _ = DB.Exec(`
-INSERT INTO conn_log(host_id,start_at,end_at,duration_ms,resolved_ip,exit_code,local_user)
-VALUES(?,?,?,?,?,?,?)
-`, id,
-			start.UTC().Format(time.RFC3339),
-			end.UTC().Format(time.RFC3339),
-			end.Sub(start).Milliseconds(),
-			ip,
-			exitCode,
-			localUser,
-		)
+		err = c.Run()
+		logConn(h.ID, start, time.Now(), t, exitCodeOf(err), "")
 
 		return err
 	},
@@ -95,4 +108,229 @@ VALUES(?,?,?,?,?,?,?)
 
 func init() {
 	sshCmd.Flags().BoolVar(&sshDryRun, "dry-run", false, "print ssh command without executing")
+	sshCmd.Flags().BoolVar(&sshWake, "wake", false, "if the host does not answer, send Wake-on-LAN and wait for it (see `sshmgr wake`)")
+	sshCmd.Flags().StringVar(&sshResolve, "resolve", "hostname,last-ip",
+		"resolution order, comma-separated: hostname|last-ip|reassociate")
+	sshCmd.Flags().StringVar(&sshSubnet, "subnet", "", "targets for the reassociate step: subnets, ranges, addresses (default: local LANs)")
+	sshCmd.Flags().BoolVar(&sshAutoPassword, "auto-password", false,
+		"answer the password prompt with the stored password (via SSH_ASKPASS, single use)")
+	engineFlag(sshCmd)
+}
+
+// autoPasswordArgs: askpass 只能回答一次密码，其他提示（如 known_hosts 确认）无法回答
+func autoPasswordArgs(h sshHost, t sshTarget) []string {
+	args := []string{"-o", "NumberOfPasswordPrompts=1"}
+	for _, o := range h.Options {
+		if strings.EqualFold(o.Key, "StrictHostKeyChecking") {
+			return args
+		}
+	}
+	// sshmgr 没确认过 host key 时按用户自己的 known_hosts 策略来，
+	// 不然密码会交给一个谁都没校验过的 key
+	if !t.Verified {
+		fmt.Fprintf(os.Stderr, "%s: host key not verified by sshmgr (%s), leaving the check to ssh and known_hosts\n", h.Name, t.Path)
+		return args
+	}
+	return append(args, "-o", "StrictHostKeyChecking=accept-new")
+}
+
+// sshNative 用内置客户端连接（--engine native）
+func sshNative(h sshHost, t sshTarget) error {
+	s, err := newNativeSession(h, t, 10*time.Second, true)
+	if err != nil {
+		return err
+	}
+	if sshDryRun {
+		fmt.Printf("dry-run (%s): native %s\n", t.Path, s.describe())
+		return nil
+	}
+	if len(s.Ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "native engine ignores: %s\n", strings.Join(s.Ignored, ", "))
+	}
+
+	start := time.Now()
+	code, err := s.shell()
+	logConn(h.ID, start, time.Now(), t, code, "")
+	if err == nil && code != 0 {
+		err = fmt.Errorf("exit status %d", code)
+	}
+	return err
+}
+
+const sshHostColumns = `id,name,user,host,port,proxy_jump,COALESCE(last_ip,'')`
+
+func scanSSHHost(sc interface{ Scan(...any) error }) (sshHost, error) {
+	var h sshHost
+	err := sc.Scan(&h.ID, &h.Name, &h.User, &h.Host, &h.Port, &h.ProxyJump, &h.LastIP)
+	return h, err
+}
+
+func loadSSHHost(name string) (sshHost, error) {
+	return scanSSHHost(DB.QueryRow(`SELECT `+sshHostColumns+` FROM hosts WHERE name=?`, name))
+}
+
+// loadSSHHostFull 同 loadSSHHost，并加载跳板机链路和 ssh 选项
+func loadSSHHostFull(name string) (sshHost, error) {
+	h, err := loadSSHHost(name)
+	if err != nil {
+		return h, err
+	}
+	return h, h.loadExtras()
+}
+
+func (h *sshHost) loadExtras() error {
+	var err error
+	if h.Jumps, err = loadJumpHosts(h.Name); err != nil {
+		return err
+	}
+	h.Options, err = effectiveOptions(h.ID)
+	return err
+}
+
+// sshArgs 生成 ssh 参数（不含远端命令），最后一个是 user@addr。
+func (h sshHost) sshArgs(t sshTarget) []string {
+	args := []string{"-p", fmt.Sprintf("%d", h.Port)}
+	if t.Addr != h.Host {
+		// 按 IP 连接时仍用主机名查 known_hosts
+		args = append(args, "-o", "HostKeyAlias="+h.Host)
+	}
+	for _, o := range h.Options {
+		args = append(args, "-o", o.Arg())
+	}
+	if j := h.jumpSpec(); j != "" {
+		args = append(args, "-J", j)
+	}
+	return append(args, fmt.Sprintf("%s@%s", h.User, t.Addr))
+}
+
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		return ee.ProcessState.ExitCode()
+	}
+	return -1
+}
+
+// logConn 写一条 conn_log；command 为空表示交互式 ssh 会话。
+func logConn(hostID int64, start, end time.Time, t sshTarget, exitCode int, command string) {
+	localUser := ""
+	if me, e := user.Current(); e == nil {
+		localUser = me.Username
+	}
+
+	_, _ = DB.Exec(`
+INSERT INTO conn_log(host_id,start_at,end_at,duration_ms,resolved_ip,exit_code,local_user,resolve_path,command)
+VALUES(?,?,?,?,?,?,?,?,?)
+`, hostID,
+		start.UTC().Format(time.RFC3339),
+		end.UTC().Format(time.RFC3339),
+		end.Sub(start).Milliseconds(),
+		t.IP,
+		exitCode,
+		localUser,
+		t.Path,
+		command,
+	)
+}
+
+// resolveSSHTarget 按 order（逗号分隔）依次尝试：
+//   - hostname: 正常解析主机名（host key 变了直接拒绝，不再往下试）
+//   - last-ip: 上次的 IP，必须 host key 与固定的一致才用
+//   - reassociate: 先查邻居表里已知 MAC 的 IP，再按指纹扫 subnet 找回新 IP
+//
+// 经跳板机连接时由跳板机解析，直接用主机名。
+func resolveSSHTarget(h sshHost, order, subnet string) (sshTarget, error) {
+	if h.jumpSpec() != "" {
+		return sshTarget{Addr: h.Host, Path: "jump"}, nil
+	}
+
+	var tried []string
+	for _, step := range strings.Split(order, ",") {
+		step = strings.TrimSpace(step)
+		switch step {
+		case "hostname":
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			ip, err := netx.ResolveHost(ctx, h.Host)
+			cancel()
+			if err != nil || ip == "" {
+				tried = append(tried, "hostname: cannot resolve "+h.Host)
+				continue
+			}
+			if h.LastIP != "" && h.LastIP != ip {
+				fmt.Printf("IP changed: %s -> %s\n", h.LastIP, ip)
+			}
+			matched, err := checkHostKey(h.Name, h.ID, ip, h.Port)
+			if err != nil {
+				return sshTarget{}, err
+			}
+			// 只有确认是这台主机才更新 last_ip；连接用验证过的 IP，不让 ssh 再解析一次
+			if matched {
+				_ = recordIP(h.ID, ip, "ssh")
+			}
+			return sshTarget{Addr: ip, IP: ip, Path: step, Verified: matched && hostKeyPolicy != "off"}, nil
+
+		case "last-ip":
+			if h.LastIP == "" {
+				tried = append(tried, "last-ip: none recorded")
+				continue
+			}
+			ok, err := hostKeyMatches(h.ID, h.LastIP, h.Port)
+			if err != nil {
+				return sshTarget{}, err
+			}
+			if !ok {
+				tried = append(tried, "last-ip: "+h.LastIP+" does not present the pinned host key")
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "%s: using last_ip %s (host key verified)\n", h.Name, h.LastIP)
+			_ = recordIP(h.ID, h.LastIP, "ssh")
+			return sshTarget{Addr: h.LastIP, IP: h.LastIP, Path: step, Verified: true}, nil
+
+		case "reassociate":
+			fps, err := knownFingerprints(h.ID, h.Name)
+			if err != nil {
+				return sshTarget{}, err
+			}
+			if len(fps) == 0 {
+				tried = append(tried, "reassociate: no known host key")
+				continue
+			}
+			if ip := findByMAC(h.ID, h.User, h.Host, h.Port, fps); ip != "" {
+				applyReassociation(h.ID, h.Name, ip, fps)
+				fmt.Fprintf(os.Stderr, "reassociated %s -> %s (by MAC)\n", h.Name, ip)
+				return sshTarget{Addr: ip, IP: ip, Path: step, Verified: true}, nil
+			}
+			subnets := []string{subnet}
+			if subnet == "" {
+				if subnets, err = localSubnets(); err != nil {
+					tried = append(tried, "reassociate: "+err.Error())
+					continue
+				}
+				subnet = strings.Join(subnets, ",")
+			}
+			ips, err := expandTargets(subnets, nil)
+			if err != nil {
+				return sshTarget{}, err
+			}
+			fmt.Fprintf(os.Stderr, "scanning %s for %s ...\n", subnet, h.Name)
+			ip := findOnSubnet(ips, h.User, h.Host, h.Port, fps, 800*time.Millisecond, 32)
+			if ip == "" {
+				tried = append(tried, "reassociate: not found on "+subnet)
+				continue
+			}
+			applyReassociation(h.ID, h.Name, ip, fps)
+			fmt.Fprintf(os.Stderr, "reassociated %s -> %s\n", h.Name, ip)
+			return sshTarget{Addr: ip, IP: ip, Path: step, Verified: true}, nil
+
+		case "":
+		default:
+			return sshTarget{}, fmt.Errorf("invalid --resolve step: %s (want hostname|last-ip|reassociate)", step)
+		}
+	}
+
+	// 都不行时保持旧行为：交给 ssh 自己解析主机名
+	fmt.Fprintf(os.Stderr, "could not resolve %s, trying ssh with the hostname anyway:\n  %s\n", h.Name, strings.Join(tried, "\n  "))
+	return sshTarget{Addr: h.Host, Path: "unresolved"}, nil
 }
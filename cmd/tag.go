@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
+	"sshmgr/internal/selector"
+)
+
+// selectExpr 是各命令共用的 --select 选择器
+var selectExpr string
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage host tags (groups)",
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <tag> [name...]",
+	Short: "Add a tag to hosts (by name and/or --select)",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTagChange(args[0], args[1:], true)
+	},
+}
+
+var tagRmCmd = &cobra.Command{
+	Use:   "rm <tag> [name...]",
+	Short: "Remove a tag from hosts (by name and/or --select)",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTagChange(args[0], args[1:], false)
+	},
+}
+
+var tagLsCmd = &cobra.Command{
+	Use:   "ls [name]",
+	Short: "List tags with host counts, or the tags of one host",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			id, err := hostID(args[0])
+			if err != nil {
+				return err
+			}
+			tags, err := hostTags(id)
+			if err != nil {
+				return err
+			}
+			out := render.New(render.Column{Key: "tag", Header: "TAG"})
+			for _, t := range tags {
+				out.Add(t)
+			}
+			return writeRows(out)
+		}
+
+		rows, err := DB.Query(`
+SELECT t.name, COUNT(ht.host_id), COALESCE(group_concat(h.name, ','), '')
+FROM tags t
+LEFT JOIN host_tags ht ON ht.tag_id=t.id
+LEFT JOIN hosts h ON h.id=ht.host_id
+GROUP BY t.id
+ORDER BY t.name COLLATE NOCASE`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out := render.New(
+			render.Column{Key: "tag", Header: "TAG"},
+			render.Column{Key: "hosts", Header: "HOSTS"},
+			render.Column{Key: "names", Header: "NAMES"},
+		)
+		for rows.Next() {
+			var tag, names string
+			var n int
+			if err := rows.Scan(&tag, &n, &names); err != nil {
+				return err
+			}
+			list := strings.Split(names, ",")
+			if names == "" {
+				list = nil
+			}
+			sort.Strings(list)
+			out.Add(tag, n, strings.Join(list, ","))
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return writeRows(out)
+	},
+}
+
+func init() {
+	selectFlag(tagAddCmd, tagRmCmd)
+	tagCmd.AddCommand(tagAddCmd, tagRmCmd, tagLsCmd)
+	rootCmd.AddCommand(tagCmd)
+}
+
+func runTagChange(tag string, names []string, add bool) error {
+	tag = strings.TrimSpace(tag)
+	if err := checkTag(tag); err != nil {
+		return err
+	}
+	if len(names) == 0 && selectExpr == "" {
+		return fmt.Errorf("give host names or --select")
+	}
+
+	ids := map[int64]string{}
+	for _, n := range names {
+		id, err := hostID(n)
+		if err != nil {
+			return err
+		}
+		ids[id] = n
+	}
+	if selectExpr != "" {
+		cond, condArgs, err := selectSQL("h")
+		if err != nil {
+			return err
+		}
+		rows, err := DB.Query(`SELECT h.id,h.name FROM hosts h WHERE `+cond, condArgs...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int64
+			var n string
+			if err := rows.Scan(&id, &n); err != nil {
+				rows.Close()
+				return err
+			}
+			ids[id] = n
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	changed := 0
+	for id := range ids {
+		var res sql.Result
+		var err error
+		if add {
+			res, err = addHostTag(id, tag)
+		} else {
+			res, err = DB.Exec(`
+DELETE FROM host_tags
+WHERE host_id=? AND tag_id=(SELECT id FROM tags WHERE name=?)`, id, tag)
+		}
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		changed += int(n)
+	}
+	if !add {
+		if err := pruneTags(); err != nil {
+			return err
+		}
+	}
+
+	verb := "tagged"
+	if !add {
+		verb = "untagged"
+	}
+	fmt.Printf("%s %d host(s) with %s (%d matched)\n", verb, changed, tag, len(ids))
+	return nil
+}
+
+func hostID(name string) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT id FROM hosts WHERE name=?`, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("not found: %s", name)
+	}
+	return id, err
+}
+
+var tagNameRe = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// checkTag 限制标签名的字符，这样在 --select 里不会被当成 = ! , 或通配符
+func checkTag(tag string) error {
+	if !tagNameRe.MatchString(tag) {
+		return fmt.Errorf("invalid tag %q (letters, digits, '.', '_' and '-' only)", tag)
+	}
+	return nil
+}
+
+// parseTags 把逗号分隔的标签拆开，去掉空白和重复（忽略大小写）
+func parseTags(s string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
+		}
+		seen[strings.ToLower(t)] = true
+		out = append(out, t)
+	}
+	return out
+}
+
+// ensureTag 返回标签 id，不存在时创建；标签名不区分大小写
+func ensureTag(name string) (int64, error) {
+	if _, err := DB.Exec(`INSERT OR IGNORE INTO tags(name) VALUES(?)`, name); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := DB.QueryRow(`SELECT id FROM tags WHERE name=?`, name).Scan(&id)
+	return id, err
+}
+
+// setHostTags 用 tags 整体替换一台主机的标签
+func setHostTags(id int64, tags []string) error {
+	if _, err := DB.Exec(`DELETE FROM host_tags WHERE host_id=?`, id); err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if _, err := addHostTag(id, t); err != nil {
+			return err
+		}
+	}
+	return pruneTags()
+}
+
+func addHostTag(id int64, tag string) (sql.Result, error) {
+	if err := checkTag(tag); err != nil {
+		return nil, err
+	}
+	tagID, err := ensureTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return DB.Exec(`INSERT OR IGNORE INTO host_tags(host_id,tag_id) VALUES(?,?)`, id, tagID)
+}
+
+func hostTags(id int64) ([]string, error) {
+	rows, err := DB.Query(`
+SELECT t.name FROM host_tags ht JOIN tags t ON t.id=ht.tag_id
+WHERE ht.host_id=? ORDER BY t.name COLLATE NOCASE`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// pruneTags 删掉已经没有主机使用的标签
+func pruneTags() error {
+	_, err := DB.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM host_tags)`)
+	return err
+}
+
+// selectFlag 给命令加 --select
+func selectFlag(cmds ...*cobra.Command) {
+	for _, c := range cmds {
+		c.Flags().StringVar(&selectExpr, "select", "",
+			"host selector, e.g. 'tag=lab,!tag=retired,name=mac-*' (globs, case-insensitive)")
+	}
+}
+
+// selectSQL 把 --select 转成 hosts 表（别名 alias）上的 WHERE 条件
+func selectSQL(alias string) (string, []any, error) {
+	sel, err := selector.Parse(selectExpr)
+	if err != nil {
+		return "", nil, err
+	}
+	cond, args := sel.SQL(alias)
+	return cond, args, nil
+}
@@ -0,0 +1,573 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/app"
+	"sshmgr/internal/db"
+	"sshmgr/internal/netx"
+	"sshmgr/internal/render"
+	"sshmgr/internal/sshconfig"
+	"sshmgr/internal/sys"
+)
+
+var (
+	tunnelLocal   string
+	tunnelRemote  string
+	tunnelDynamic string
+	tunnelCheck   int
+)
+
+// tunnelDef 是一条保存的转发；Kind 为 L/R/D，对应 ssh -L/-R/-D
+type tunnelDef struct {
+	Name string
+	Kind string
+	Spec string
+}
+
+func (t tunnelDef) String() string { return t.Kind + " " + t.Spec }
+
+// tunnelState 是后台 supervisor 的运行状态，存在 run/<name>.json
+type tunnelState struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	Addr    string    `json:"addr"`
+	Tunnels []string  `json:"tunnels"`
+}
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel",
+	Short: "Saved port forwards run in the background (ControlMaster, reconnects on IP change)",
+}
+
+var tunnelAddCmd = &cobra.Command{
+	Use:   "add <host> <tunnel> (-L spec | -R spec | -D spec)",
+	Short: "Save a forward, e.g. `tunnel add mini vnc -L 5900`",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var defs []tunnelDef
+		for kind, spec := range map[string]string{"L": tunnelLocal, "R": tunnelRemote, "D": tunnelDynamic} {
+			if spec != "" {
+				defs = append(defs, tunnelDef{Name: args[1], Kind: kind, Spec: normalizeForward(kind, spec)})
+			}
+		}
+		if len(defs) != 1 {
+			return fmt.Errorf("give exactly one of -L, -R or -D")
+		}
+		id, err := hostID(args[0])
+		if err != nil {
+			return err
+		}
+
+		d := defs[0]
+		_, err = DB.Exec(`
+INSERT INTO tunnels(host_id,name,kind,spec,created_at) VALUES(?,?,?,?,?)
+ON CONFLICT(host_id,name) DO UPDATE SET kind=excluded.kind, spec=excluded.spec`,
+			id, d.Name, d.Kind, d.Spec, db.NowUTC())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s/%s: %s\n", args[0], d.Name, d)
+		return nil
+	},
+}
+
+var tunnelRmCmd = &cobra.Command{
+	Use:   "rm <host> <tunnel>",
+	Short: "Delete a saved forward",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := hostID(args[0])
+		if err != nil {
+			return err
+		}
+		if st, ok := loadTunnelState(args[0]); ok && contains(st.Tunnels, args[1]) {
+			return fmt.Errorf("%s/%s is up, run `sshmgr tunnel down %s %s` first", args[0], args[1], args[0], args[1])
+		}
+		res, err := DB.Exec(`DELETE FROM tunnels WHERE host_id=? AND name=?`, id, args[1])
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("not found: %s/%s", args[0], args[1])
+		}
+		return nil
+	},
+}
+
+var tunnelUpCmd = &cobra.Command{
+	Use:   "up <host> [tunnel...]",
+	Short: "Start saved forwards in the background (all of the host's by default)",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		id, err := hostID(name)
+		if err != nil {
+			return err
+		}
+		defs, err := tunnelDefs(id, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(defs) == 0 {
+			return fmt.Errorf("%s has no tunnels, add one with `sshmgr tunnel add`", name)
+		}
+
+		st, running := loadTunnelState(name)
+		if !running {
+			st = tunnelState{}
+			for _, d := range defs {
+				st.Tunnels = append(st.Tunnels, d.Name)
+			}
+			if err := saveTunnelState(name, st); err != nil {
+				return err
+			}
+			pid, err := startTunnelSupervisor(name)
+			if err != nil {
+				_ = os.Remove(tunnelPath(name, ".json"))
+				return err
+			}
+			// supervisor 自己也会写，这里先写上，避免紧接着的 up/ls 误判为已退出
+			if err := updateTunnelState(name, func(s *tunnelState) {
+				s.PID, s.Started = pid, time.Now().UTC()
+			}); err != nil {
+				return err
+			}
+			fmt.Printf("%s: started %s (pid %d, log %s)\n", name, defNames(defs), pid, tunnelPath(name, ".log"))
+			return nil
+		}
+
+		// 已在运行：通过 ControlMaster 追加转发，并记到状态里以便重连时带上
+		var added []tunnelDef
+		for _, d := range defs {
+			if contains(st.Tunnels, d.Name) {
+				continue
+			}
+			// 正在重连时没有 control socket，记进状态，下次连上时一起带上
+			if _, err := os.Stat(tunnelPath(name, ".sock")); err == nil {
+				if err := tunnelControl(name, "forward", d); err != nil {
+					return fmt.Errorf("%s/%s: %w", name, d.Name, err)
+				}
+			}
+			st.Tunnels = append(st.Tunnels, d.Name)
+			added = append(added, d)
+		}
+		if err := updateTunnelState(name, func(s *tunnelState) { s.Tunnels = st.Tunnels }); err != nil {
+			return err
+		}
+		if len(added) == 0 {
+			fmt.Printf("%s: already up\n", name)
+		} else {
+			fmt.Printf("%s: added %s\n", name, defNames(added))
+		}
+		return nil
+	},
+}
+
+var tunnelDownCmd = &cobra.Command{
+	Use:   "down <host> [tunnel...]",
+	Short: "Stop forwards (all of the host's by default)",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		st, running := loadTunnelState(name)
+		if !running {
+			fmt.Printf("%s: not running\n", name)
+			return nil
+		}
+
+		var keep []string
+		if len(args) > 1 {
+			id, err := hostID(name)
+			if err != nil {
+				return err
+			}
+			defs, err := tunnelDefs(id, args[1:])
+			if err != nil {
+				return err
+			}
+			_, sockErr := os.Stat(tunnelPath(name, ".sock"))
+			for _, d := range defs {
+				if contains(st.Tunnels, d.Name) && sockErr == nil {
+					if err := tunnelControl(name, "cancel", d); err != nil {
+						return fmt.Errorf("%s/%s: %w", name, d.Name, err)
+					}
+				}
+			}
+			for _, t := range st.Tunnels {
+				if !contains(args[1:], t) {
+					keep = append(keep, t)
+				}
+			}
+		}
+		if len(keep) > 0 {
+			return updateTunnelState(name, func(s *tunnelState) { s.Tunnels = keep })
+		}
+
+		p, err := os.FindProcess(st.PID)
+		if err != nil {
+			return err
+		}
+		if err := p.Signal(syscall.SIGTERM); err != nil {
+			return err
+		}
+		// 等 supervisor 写完 conn_log 并清理状态
+		for i := 0; i < 50 && sys.ProcessAlive(st.PID); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		fmt.Printf("%s: stopped\n", name)
+		return nil
+	},
+}
+
+var tunnelLsCmd = &cobra.Command{
+	Use:   "ls [host]",
+	Short: "List saved forwards and whether they are up",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host := ""
+		if len(args) == 1 {
+			host = args[0]
+		}
+		rows, err := DB.Query(`
+SELECT h.name,t.name,t.kind,t.spec
+FROM tunnels t JOIN hosts h ON h.id=t.host_id
+WHERE (?='' OR h.name=?)
+ORDER BY h.name,t.name`, host, host)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		out := render.New(
+			render.Column{Key: "host", Header: "HOST"},
+			render.Column{Key: "tunnel", Header: "TUNNEL"},
+			render.Column{Key: "forward", Header: "FORWARD"},
+			render.Column{Key: "state", Header: "STATE"},
+			render.Column{Key: "addr", Header: "ADDR"},
+			render.Column{Key: "since", Header: "SINCE", Format: localTimeFmt("01-02 15:04")},
+		)
+		states := map[string]tunnelState{}
+		for rows.Next() {
+			var h string
+			var d tunnelDef
+			if err := rows.Scan(&h, &d.Name, &d.Kind, &d.Spec); err != nil {
+				return err
+			}
+			st, ok := states[h]
+			if !ok {
+				st, _ = loadTunnelState(h)
+				states[h] = st
+			}
+			if contains(st.Tunnels, d.Name) {
+				out.Add(h, d.Name, d.String(), "up", st.Addr, st.Started)
+			} else {
+				out.Add(h, d.Name, d.String(), "down", "", time.Time{})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return writeRows(out)
+	},
+}
+
+var tunnelRunCmd = &cobra.Command{
+	Use:    "run <host>",
+	Short:  "Tunnel supervisor (started by `tunnel up`)",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTunnelSupervisor(args[0], time.Duration(tunnelCheck)*time.Second)
+	},
+}
+
+func init() {
+	tunnelAddCmd.Flags().StringVarP(&tunnelLocal, "local", "L", "", "local forward: port | [bind:]port:host:hostport")
+	tunnelAddCmd.Flags().StringVarP(&tunnelRemote, "remote", "R", "", "remote forward: port | [bind:]port:host:hostport")
+	tunnelAddCmd.Flags().StringVarP(&tunnelDynamic, "dynamic", "D", "", "SOCKS proxy: [bind:]port")
+	tunnelRunCmd.Flags().IntVar(&tunnelCheck, "check", 30, "seconds between resolver checks")
+
+	tunnelCmd.AddCommand(tunnelAddCmd, tunnelRmCmd, tunnelUpCmd, tunnelDownCmd, tunnelLsCmd, tunnelRunCmd)
+	rootCmd.AddCommand(tunnelCmd)
+}
+
+// normalizeForward 允许只写端口：-L 5900 等同 -L 5900:localhost:5900
+func normalizeForward(kind, spec string) string {
+	spec = strings.TrimSpace(spec)
+	if kind != "D" && !strings.Contains(spec, ":") {
+		return spec + ":localhost:" + spec
+	}
+	return spec
+}
+
+// tunnelDefs 读取主机的转发；names 为空时返回全部
+func tunnelDefs(id int64, names []string) ([]tunnelDef, error) {
+	rows, err := DB.Query(`SELECT name,kind,spec FROM tunnels WHERE host_id=? ORDER BY name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []tunnelDef
+	found := map[string]bool{}
+	for rows.Next() {
+		var d tunnelDef
+		if err := rows.Scan(&d.Name, &d.Kind, &d.Spec); err != nil {
+			return nil, err
+		}
+		if len(names) == 0 || contains(names, d.Name) {
+			out = append(out, d)
+			found[d.Name] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, n := range names {
+		if !found[n] {
+			return nil, fmt.Errorf("tunnel not found: %s", n)
+		}
+	}
+	return out, nil
+}
+
+func defNames(defs []tunnelDef) string {
+	names := make([]string, len(defs))
+	for i, d := range defs {
+		names[i] = d.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// tunnelPath 返回 run/<name><ext>；ext 为 .json / .sock / .log
+func tunnelPath(name, ext string) string {
+	return filepath.Join(app.ConfigDir(), "run", name+ext)
+}
+
+// loadTunnelState 读状态；supervisor 已不在时清掉残留文件并返回 false
+func loadTunnelState(name string) (tunnelState, bool) {
+	var st tunnelState
+	b, err := os.ReadFile(tunnelPath(name, ".json"))
+	if err != nil || json.Unmarshal(b, &st) != nil {
+		return tunnelState{}, false
+	}
+	if !sys.ProcessAlive(st.PID) {
+		_ = os.Remove(tunnelPath(name, ".json"))
+		_ = os.Remove(tunnelPath(name, ".sock"))
+		return tunnelState{}, false
+	}
+	return st, true
+}
+
+func saveTunnelState(name string, st tunnelState) error {
+	path := tunnelPath(name, ".json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// updateTunnelState 读-改-写，up/down 和 supervisor 都会写这个文件
+func updateTunnelState(name string, fn func(*tunnelState)) error {
+	var st tunnelState
+	if b, err := os.ReadFile(tunnelPath(name, ".json")); err == nil {
+		_ = json.Unmarshal(b, &st)
+	}
+	fn(&st)
+	return saveTunnelState(name, st)
+}
+
+// tunnelControl 通过 ControlMaster 增加/取消一条转发（ssh -O forward|cancel）
+func tunnelControl(name, op string, d tunnelDef) error {
+	c := exec.Command("ssh", "-S", tunnelPath(name, ".sock"), "-O", op, "-"+d.Kind, d.Spec, name)
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func startTunnelSupervisor(name string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	logf, err := os.OpenFile(tunnelPath(name, ".log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer logf.Close()
+
+	c := exec.Command(exe, "--db", dbPath, "--hostkey-policy", hostKeyPolicy, "tunnel", "run", name)
+	c.Stdout, c.Stderr = logf, logf
+	sys.Detach(c)
+	if err := c.Start(); err != nil {
+		return 0, err
+	}
+	pid := c.Process.Pid
+	_ = c.Process.Release()
+	return pid, nil
+}
+
+// runTunnelSupervisor 在前台跑 ssh -M -N 带上所有转发；
+// ssh 退出或主机 IP 变化时重新解析并重连，每次会话写一条 conn_log。
+func runTunnelSupervisor(name string, check time.Duration) error {
+	if check <= 0 {
+		check = 30 * time.Second
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+
+	sock := tunnelPath(name, ".sock")
+	defer func() {
+		_ = os.Remove(tunnelPath(name, ".json"))
+		_ = os.Remove(sock)
+	}()
+	if err := updateTunnelState(name, func(s *tunnelState) {
+		s.PID, s.Started = os.Getpid(), time.Now().UTC()
+	}); err != nil {
+		return err
+	}
+
+	backoff := 2 * time.Second
+	for {
+		var st tunnelState
+		if b, err := os.ReadFile(tunnelPath(name, ".json")); err == nil {
+			_ = json.Unmarshal(b, &st)
+		}
+		h, err := loadSSHHostFull(name)
+		if err != nil {
+			return err
+		}
+		defs, err := tunnelDefs(h.ID, nil)
+		if err != nil {
+			return err
+		}
+		var active []tunnelDef
+		for _, d := range defs {
+			if contains(st.Tunnels, d.Name) {
+				active = append(active, d)
+			}
+		}
+		if len(active) == 0 {
+			return nil
+		}
+
+		t, err := resolveSSHTarget(h, "hostname,last-ip", "")
+		if err != nil {
+			// host key 不对时不自动重连
+			return err
+		}
+		_ = updateTunnelState(name, func(s *tunnelState) { s.Addr = t.Addr })
+
+		args := []string{
+			"-M", "-S", sock, "-N",
+			"-o", "ControlPersist=no",
+			"-o", "ExitOnForwardFailure=yes",
+			"-o", "BatchMode=yes",
+			"-o", "ServerAliveInterval=15",
+			"-o", "ServerAliveCountMax=3",
+		}
+		for _, d := range active {
+			args = append(args, "-"+d.Kind, d.Spec)
+		}
+		h.Options = sshconfig.NonInteractive(h.Options)
+		args = append(args, h.sshArgs(t)...)
+
+		fmt.Printf("%s connecting to %s (%s): %s\n", time.Now().Format(time.RFC3339), t.Addr, t.Path, defNames(active))
+		c := exec.Command("ssh", args...)
+		c.Stdout, c.Stderr = os.Stdout, os.Stderr
+		start := time.Now()
+		if err := c.Start(); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- c.Wait() }()
+
+		ticker := time.NewTicker(check)
+		stop := false
+		var waitErr error
+	wait:
+		for {
+			select {
+			case waitErr = <-done:
+				break wait
+			case <-ticker.C:
+				if ip, changed := tunnelIPChanged(h, t); changed {
+					fmt.Printf("%s IP changed %s -> %s, reconnecting\n", time.Now().Format(time.RFC3339), t.IP, ip)
+					_ = c.Process.Signal(syscall.SIGTERM)
+					waitErr = <-done
+					break wait
+				}
+			case <-sigs:
+				stop = true
+				_ = c.Process.Signal(syscall.SIGTERM)
+				waitErr = <-done
+				break wait
+			}
+		}
+		ticker.Stop()
+
+		end := time.Now()
+		logConn(h.ID, start, end, t, exitCodeOf(waitErr), "tunnel "+defNames(active))
+		if stop {
+			return nil
+		}
+
+		// 连上过一阵子就从头计退避，否则逐步拉长到 1 分钟
+		if end.Sub(start) > time.Minute {
+			backoff = 2 * time.Second
+		} else if backoff < time.Minute {
+			backoff *= 2
+		}
+		fmt.Printf("%s ssh exited (%v), retry in %s\n", end.Format(time.RFC3339), waitErr, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-sigs:
+			return nil
+		}
+	}
+}
+
+// tunnelIPChanged 重新解析主机名，和当前连接的 IP 比较；经跳板机时不检查
+func tunnelIPChanged(h sshHost, t sshTarget) (string, bool) {
+	if t.IP == "" {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	ip, err := netx.ResolveHost(ctx, h.Host)
+	if err != nil || ip == "" {
+		return "", false
+	}
+	return ip, ip != t.IP
+}
@@ -2,18 +2,20 @@ package cmd
 
 import (
 	"database/sql"
-	"fmt"
-	"os"
-	"text/tabwriter"
-	"time"
 
 	"github.com/spf13/cobra"
+
+	"sshmgr/internal/render"
 )
 
 var usersCmd = &cobra.Command{
 	Use:   "users",
 	Short: "List entries as: name host ip count last pw",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cond, condArgs, err := selectSQL("h")
+		if err != nil {
+			return err
+		}
 		rows, err := DB.Query(`
 SELECT
   h.name,
@@ -25,21 +27,29 @@ SELECT
   (SELECT c.end_at FROM conn_log c WHERE c.host_id = h.id ORDER BY c.id DESC LIMIT 1) AS last_connected_at,
   (SELECT c.resolved_ip FROM conn_log c WHERE c.host_id = h.id ORDER BY c.id DESC LIMIT 1) AS last_connected_ip
 FROM hosts h
+WHERE `+cond+`
 ORDER BY COALESCE(last_connected_at, '') DESC, h.name ASC;
-`)
+`, condArgs...)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "NAME\tHOST\tIP\tCOUNT\tLAST\tPW")
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "user"},
+			render.Column{Key: "host", Header: "HOST"},
+			render.Column{Key: "ip", Header: "IP"},
+			render.Column{Key: "conn_count", Header: "COUNT"},
+			render.Column{Key: "last_connected_at", Header: "LAST", Format: localTimeFmt("01-02 15:04")},
+			render.Column{Key: "has_password", Header: "PW", Format: yesNo},
+		)
 
 		for rows.Next() {
 			var (
-				name       string
-				userIgnore string
-				host       string
+				name string
+				user string
+				host string
 
 				lastIP     sql.NullString
 				hasSecret  int
@@ -48,7 +58,7 @@ ORDER BY COALESCE(last_connected_at, '') DESC, h.name ASC;
 				lastConnIP sql.NullString
 			)
 
-			if err := rows.Scan(&name, &userIgnore, &host, &lastIP, &hasSecret, &connCount, &lastConnAt, &lastConnIP); err != nil {
+			if err := rows.Scan(&name, &user, &host, &lastIP, &hasSecret, &connCount, &lastConnAt, &lastConnIP); err != nil {
 				return err
 			}
 
@@ -59,24 +69,16 @@ ORDER BY COALESCE(last_connected_at, '') DESC, h.name ASC;
 				ip = lastIP.String
 			}
 
-			last := ""
-			if lastConnAt.Valid && lastConnAt.String != "" {
-				if t, e := time.Parse(time.RFC3339, lastConnAt.String); e == nil {
-					last = t.Local().Format("01-02 15:04")
-				} else {
-					last = lastConnAt.String
-				}
-			}
-
-			pw := "no"
-			if hasSecret != 0 {
-				pw = "yes"
-			}
-
-			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", name, host, ip, connCount, last, pw)
+			out.Add(name, user, host, ip, connCount, timeValue(lastConnAt.String), hasSecret != 0)
+		}
+		if err := rows.Err(); err != nil {
+			return err
 		}
 
-		_ = w.Flush()
-		return rows.Err()
+		return writeRows(out)
 	},
 }
+
+func init() {
+	selectFlag(usersCmd)
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"sshmgr/internal/db"
+	"sshmgr/internal/neigh"
+	"sshmgr/internal/netif"
+	"sshmgr/internal/render"
+	"sshmgr/internal/wol"
+)
+
+var (
+	wakePort int
+	wakeWait time.Duration
+	wakeMAC  string
+)
+
+// ssh --wake 等主机起来的最长时间
+const sshWakeWait = 90 * time.Second
+
+type wakeResult struct {
+	Name   string
+	MAC    string
+	Sent   int // 发往的地址数
+	ST     string
+	Waited time.Duration
+	Err    error
+}
+
+var wakeCmd = &cobra.Command{
+	Use:   "wake <name|tag>... | --select expr",
+	Short: "Wake hosts with Wake-on-LAN magic packets (optionally wait until SSH answers)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rows, err := selectWakeHosts(args)
+		if err != nil {
+			return err
+		}
+		if wakeMAC != "" {
+			if len(rows) != 1 {
+				return errors.New("--mac needs exactly one host")
+			}
+			if err := setHostMAC(rows[0].ID, wakeMAC); err != nil {
+				return err
+			}
+		}
+
+		results := make([]wakeResult, len(rows))
+		var wg sync.WaitGroup
+		for i, r := range rows {
+			wg.Add(1)
+			go func(i int, r pingRow) {
+				defer wg.Done()
+				results[i] = wakeHost(r, wakeWait)
+			}(i, r)
+		}
+		wg.Wait()
+
+		out := render.New(
+			render.Column{Key: "name", Header: "NAME"},
+			render.Column{Key: "mac", Header: "MAC"},
+			render.Column{Key: "sent_to", Header: "SENT_TO"},
+			render.Column{Key: "status", Header: "ST"},
+			render.Column{Key: "waited_s", Header: "WAITED"},
+			render.Column{Key: "error", Header: "ERROR"},
+		)
+		fail := 0
+		for _, r := range results {
+			errText := ""
+			if r.Err != nil {
+				errText = r.Err.Error()
+			}
+			switch r.ST {
+			case "UP", "WOKE", "SENT":
+			default:
+				fail++
+			}
+			out.Add(r.Name, r.MAC, r.Sent, r.ST, int(r.Waited.Seconds()), errText)
+		}
+		if err := writeRows(out); err != nil {
+			return err
+		}
+		if fail > 0 {
+			return fmt.Errorf("%d host(s) not woken", fail)
+		}
+		return nil
+	},
+}
+
+func init() {
+	wakeCmd.Flags().IntVar(&wakePort, "port", wol.DefaultPort, "UDP port for the magic packet (usually 9 or 7)")
+	wakeCmd.Flags().DurationVar(&wakeWait, "wait", 0, "wait up to this long for SSH to answer, e.g. 90s (0 = just send)")
+	wakeCmd.Flags().StringVar(&wakeMAC, "mac", "", "MAC address to use and remember for the host")
+	selectFlag(wakeCmd)
+	rootCmd.AddCommand(wakeCmd)
+}
+
+// selectWakeHosts 每个参数先当主机名，没有这台主机再当标签
+func selectWakeHosts(args []string) ([]pingRow, error) {
+	if len(args) == 0 {
+		if selectExpr == "" {
+			return nil, errors.New("give host names, tags or --select")
+		}
+		return loadAllHosts()
+	}
+	var out []pingRow
+	seen := map[int64]bool{}
+	for _, a := range args {
+		rows, err := loadPingRows(`WHERE name=?`, a)
+		if err == nil && len(rows) == 0 {
+			rows, err = loadPingRows(`
+WHERE id IN (SELECT ht.host_id FROM host_tags ht JOIN tags t ON t.id=ht.tag_id WHERE t.name=?)`, a)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("no host or tag named %q", a)
+		}
+		for _, r := range rows {
+			if !seen[r.ID] {
+				seen[r.ID] = true
+				out = append(out, r)
+			}
+		}
+	}
+	return out, nil
+}
+
+func setHostMAC(hostID int64, mac string) error {
+	m := neigh.NormalizeMAC(mac)
+	if m == "" {
+		return fmt.Errorf("invalid MAC address: %s", mac)
+	}
+	now := db.NowUTC()
+	_, err := DB.Exec(`
+INSERT INTO host_macs(host_id,mac,first_seen_at,last_seen_at) VALUES(?,?,?,?)
+ON CONFLICT(host_id,mac) DO UPDATE SET last_seen_at=excluded.last_seen_at`, hostID, m, now, now)
+	return err
+}
+
+// wakeHost 发送唤醒包；wait > 0 时用 ping 的逻辑等 SSH 端口应答，
+// 期间每 10 秒重发一次。已经在线的主机不发包。
+func wakeHost(r pingRow, wait time.Duration) wakeResult {
+	res := wakeResult{Name: r.Name, ST: "ERR"}
+	if r.Jump != "" {
+		res.Err = errors.New("behind a jump host; magic packets do not cross routers")
+		return res
+	}
+	macs, err := hostMACs(r.ID)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	if len(macs) == 0 {
+		res.ST = "NOMAC"
+		res.Err = fmt.Errorf("no MAC known (learned by check/ping on the same LAN, or give --mac)")
+		return res
+	}
+	res.MAC = strings.Join(macs, ",")
+
+	if wait > 0 {
+		if p := pingOne(r, 2*time.Second); p.ST == "OK" {
+			res.ST = "UP"
+			return res
+		}
+	}
+
+	var lastIP string
+	_ = DB.QueryRow(`SELECT last_ip FROM hosts WHERE id=?`, r.ID).Scan(&lastIP)
+	dsts := wakeAddrs(lastIP, wakePort)
+	send := func() error {
+		var errs []error
+		for _, m := range macs {
+			if err := wol.Send(m, dsts); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) == len(macs) {
+			return errors.Join(errs...)
+		}
+		return nil
+	}
+	if err := send(); err != nil {
+		res.Err = err
+		return res
+	}
+	res.Sent = len(dsts)
+	res.ST = "SENT"
+	if wait <= 0 {
+		return res
+	}
+
+	start := time.Now()
+	lastSend := start
+	for time.Since(start) < wait {
+		p := pingOne(r, 2*time.Second)
+		switch p.ST {
+		case "OK":
+			res.ST = "WOKE"
+			res.Waited = time.Since(start)
+			return res
+		case "HOSTKEY":
+			res.ST = "HOSTKEY"
+			res.Err = errors.New("host key does not match the pinned one")
+			return res
+		}
+		if time.Since(lastSend) >= 10*time.Second {
+			_ = send()
+			lastSend = time.Now()
+		}
+		time.Sleep(2 * time.Second)
+	}
+	res.ST = "TIMEOUT"
+	res.Waited = time.Since(start)
+	res.Err = fmt.Errorf("SSH did not answer within %s", wait)
+	return res
+}
+
+// wakeAddrs 返回唤醒包的目标：255.255.255.255、每个本地局域网的定向广播，
+// 以及上次的 IP（单播；不在本地网段时再加它所在 /24 的广播）
+func wakeAddrs(lastIP string, port int) []netip.AddrPort {
+	var out []netip.AddrPort
+	seen := map[netip.Addr]bool{}
+	add := func(a netip.Addr) {
+		if a.IsValid() && a.Is4() && !seen[a] {
+			seen[a] = true
+			out = append(out, netip.AddrPortFrom(a, uint16(port)))
+		}
+	}
+	add(netip.AddrFrom4([4]byte{255, 255, 255, 255}))
+
+	nets, _ := netif.Subnets(netif.Policy{})
+	last, _ := netip.ParseAddr(lastIP)
+	last = last.Unmap()
+	local := false
+	for _, n := range nets {
+		if b, ok := wol.Broadcast(n.Prefix); ok {
+			add(b)
+		}
+		if last.IsValid() && n.Prefix.Contains(last) {
+			local = true
+		}
+	}
+	if last.Is4() {
+		add(last)
+		if !local {
+			if b, ok := wol.Broadcast(netip.PrefixFrom(last, 24)); ok {
+				add(b)
+			}
+		}
+	}
+	return out
+}
+
+// wakeBeforeSSH 是 ssh --wake 的前置步骤：主机不在线就唤醒并等它起来。
+// 经跳板机的主机收不到本机发的唤醒包，直接跳过，由 ssh 自己连。
+func wakeBeforeSSH(h sshHost) error {
+	r := pingRow{ID: h.ID, Name: h.Name, Host: h.Host, Port: h.Port, Jump: h.jumpSpec()}
+	if r.Jump != "" || pingOne(r, 2*time.Second).ST == "OK" {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "waking %s ...\n", h.Name)
+	res := wakeHost(r, sshWakeWait)
+	switch res.ST {
+	case "UP", "WOKE":
+		fmt.Fprintf(os.Stderr, "%s is up after %ds\n", h.Name, int(res.Waited.Seconds()))
+		return nil
+	}
+	return fmt.Errorf("wake %s: %w", h.Name, res.Err)
+}
@@ -4,6 +4,8 @@ go 1.22
 
 require (
 	github.com/spf13/cobra v1.9.1
+	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.33.0
 	golang.org/x/term v0.27.0
 	modernc.org/sqlite v1.34.5
 )
@@ -20,4 +22,4 @@ require (
 	modernc.org/libc v1.55.3 // indirect
 	modernc.org/mathutil v1.6.0 // indirect
 	modernc.org/memory v1.8.0 // indirect
-)
\ No newline at end of file
+)
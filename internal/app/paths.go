@@ -5,9 +5,13 @@ import (
 	"path/filepath"
 )
 
-func DefaultDBPath() string {
+func ConfigDir() string {
 	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config", "sshmgr", "sshmgr.db")
+	return filepath.Join(home, ".config", "sshmgr")
+}
+
+func DefaultDBPath() string {
+	return filepath.Join(ConfigDir(), "sshmgr.db")
 }
 
 func EnsureParentDir(path string) error {
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"database/sql/driver"
+
+	"modernc.org/sqlite"
+
+	"sshmgr/internal/selector"
+)
+
+// --select 在 SQL 里也用 selector.Glob，和内存里的 Match 结果一致
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction(selector.SQLFunc, 2,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			p, _ := args[0].(string)
+			s, _ := args[1].(string)
+			return selector.Glob(p, s), nil
+		})
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"sshmgr/internal/selector"
+)
+
+// SQL 里注册的函数和 selector.Glob 必须给出同样的结果
+func TestGlobFuncMatchesSelector(t *testing.T) {
+	d, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	cases := []struct{ pattern, s string }{
+		{"lab*", "lab-07"}, {"web/*", "web/a/b"}, {"lab-[^0-9]", "lab-x"}, {"lab-[!0-9]", "lab-7"},
+		{"[]a]", "]"}, {"l?b", "lb"}, {"?", "é"}, {"*a*b", "aaab"}, {"[lab", "[lab"},
+	}
+	for _, c := range cases {
+		var got bool
+		if err := d.QueryRow(`SELECT `+selector.SQLFunc+`(?, ?)`, c.pattern, c.s).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if want := selector.Glob(c.pattern, c.s); got != want {
+			t.Errorf("%s(%q, %q) = %v, Glob = %v", selector.SQLFunc, c.pattern, c.s, got, want)
+		}
+	}
+}
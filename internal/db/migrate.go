@@ -0,0 +1,364 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Migration is one ordered, forward-only schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+}
+
+// migrations 按 Version 递增排列；已发布的条目不要修改，只能追加新条目。
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		// 沿用旧版 Init 的建表语句（IF NOT EXISTS），老库可以直接标记为 v1
+		Up: `
+CREATE TABLE IF NOT EXISTS hosts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  user TEXT NOT NULL,
+  host TEXT NOT NULL,
+  port INTEGER NOT NULL DEFAULT 22,
+  note TEXT DEFAULT '',
+  tags TEXT DEFAULT '',
+  last_ip TEXT DEFAULT '',
+  last_checked_at TEXT DEFAULT '',
+  has_secret INTEGER NOT NULL DEFAULT 0,
+  created_at TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS conn_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  host_id INTEGER NOT NULL,
+  start_at TEXT NOT NULL,
+  end_at TEXT NOT NULL,
+  duration_ms INTEGER NOT NULL,
+  resolved_ip TEXT DEFAULT '',
+  exit_code INTEGER NOT NULL,
+  local_user TEXT DEFAULT '',
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_conn_log_host_time ON conn_log(host_id, start_at);
+`,
+	},
+	{
+		Version: 2,
+		Name:    "pinned host keys",
+		Up: `
+CREATE TABLE host_keys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  host_id INTEGER NOT NULL,
+  key_type TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  first_seen_at TEXT NOT NULL,
+  last_seen_at TEXT NOT NULL,
+  UNIQUE(host_id, key_type),
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+);
+`,
+	},
+	{
+		Version: 3,
+		Name:    "ip history",
+		Up: `
+CREATE TABLE ip_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  host_id INTEGER NOT NULL,
+  ip TEXT NOT NULL,
+  first_seen_at TEXT NOT NULL,
+  last_seen_at TEXT NOT NULL,
+  source TEXT NOT NULL DEFAULT '',
+  seen_count INTEGER NOT NULL DEFAULT 1,
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ip_history_host ON ip_history(host_id, id);
+CREATE INDEX idx_ip_history_ip ON ip_history(ip);
+
+-- 把已有的 last_ip 作为时间线起点
+INSERT INTO ip_history(host_id,ip,first_seen_at,last_seen_at,source)
+SELECT id, last_ip, COALESCE(NULLIF(last_checked_at,''), created_at), COALESCE(NULLIF(last_checked_at,''), created_at), 'migrate'
+FROM hosts WHERE last_ip <> '';
+`,
+	},
+	{
+		Version: 4,
+		Name:    "identity file and proxy jump",
+		Up: `
+ALTER TABLE hosts ADD COLUMN identity_file TEXT NOT NULL DEFAULT '';
+ALTER TABLE hosts ADD COLUMN proxy_jump TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		Version: 5,
+		Name:    "conn_log resolve path",
+		Up: `
+ALTER TABLE conn_log ADD COLUMN resolve_path TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		Version: 6,
+		Name:    "conn_log command",
+		Up: `
+ALTER TABLE conn_log ADD COLUMN command TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		Version: 7,
+		Name:    "normalized tags",
+		Up: `
+CREATE TABLE tags (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE COLLATE NOCASE
+);
+
+CREATE TABLE host_tags (
+  host_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY(host_id, tag_id),
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+  FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_host_tags_tag ON host_tags(tag_id);
+
+-- 拆分 hosts.tags（逗号分隔）搬进新表，然后删掉旧列
+CREATE TEMP TABLE split_tags AS
+WITH RECURSIVE s(host_id, tag, rest) AS (
+  SELECT id, '', tags || ',' FROM hosts WHERE COALESCE(tags,'') <> ''
+  UNION ALL
+  SELECT host_id, trim(substr(rest, 1, instr(rest, ',') - 1)), substr(rest, instr(rest, ',') + 1)
+  FROM s WHERE rest <> ''
+)
+SELECT host_id, tag FROM s WHERE tag <> '';
+
+INSERT OR IGNORE INTO tags(name) SELECT tag FROM split_tags ORDER BY rowid;
+INSERT OR IGNORE INTO host_tags(host_id, tag_id)
+SELECT st.host_id, t.id FROM split_tags st JOIN tags t ON t.name = st.tag;
+
+DROP TABLE split_tags;
+
+ALTER TABLE hosts DROP COLUMN tags;
+`,
+	},
+	{
+		Version: 8,
+		Name:    "jump chains",
+		Up: `
+CREATE TABLE host_jumps (
+  host_id INTEGER NOT NULL,
+  seq INTEGER NOT NULL,
+  jump_id INTEGER NOT NULL,
+  PRIMARY KEY(host_id, seq),
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+  FOREIGN KEY(jump_id) REFERENCES hosts(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX idx_host_jumps_jump ON host_jumps(jump_id);
+`,
+	},
+	{
+		Version: 9,
+		Name:    "ssh options",
+		Up: `
+CREATE TABLE host_options (
+  host_id INTEGER NOT NULL,
+  key TEXT NOT NULL COLLATE NOCASE,
+  value TEXT NOT NULL,
+  PRIMARY KEY(host_id, key),
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+);
+
+-- 全局默认选项，主机上的同名选项优先
+CREATE TABLE default_options (
+  key TEXT NOT NULL PRIMARY KEY COLLATE NOCASE,
+  value TEXT NOT NULL
+);
+
+INSERT INTO host_options(host_id,key,value)
+SELECT id, 'IdentityFile', identity_file FROM hosts WHERE identity_file <> '';
+
+ALTER TABLE hosts DROP COLUMN identity_file;
+`,
+	},
+	{
+		Version: 10,
+		Name:    "tunnels",
+		Up: `
+CREATE TABLE tunnels (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  host_id INTEGER NOT NULL,
+  name TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  spec TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  UNIQUE(host_id, name),
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+);
+`,
+	},
+	{
+		Version: 11,
+		Name:    "login keys",
+		Up: `
+-- 用户登录用的密钥（sshmgr key gen），和 host_keys（服务端 host key）不是一回事
+CREATE TABLE ssh_keys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  path TEXT NOT NULL,
+  key_type TEXT NOT NULL,
+  fingerprint TEXT NOT NULL UNIQUE,
+  public_key TEXT NOT NULL,
+  created_at TEXT NOT NULL
+);
+
+-- 每台主机当前使用的密钥，由 key push / key rotate 写入
+CREATE TABLE host_ssh_keys (
+  host_id INTEGER NOT NULL PRIMARY KEY,
+  key_id INTEGER NOT NULL,
+  installed_at TEXT NOT NULL,
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+  FOREIGN KEY(key_id) REFERENCES ssh_keys(id) ON DELETE RESTRICT
+);
+`,
+	},
+	{
+		Version: 12,
+		Name:    "host mac addresses",
+		Up: `
+-- 同一链路上看到的 MAC（邻居表），只在 host key 验证通过后记录；
+-- 一台主机可能有多块网卡（有线/无线）
+CREATE TABLE host_macs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  host_id INTEGER NOT NULL,
+  mac TEXT NOT NULL,
+  last_ip TEXT NOT NULL DEFAULT '',
+  first_seen_at TEXT NOT NULL,
+  last_seen_at TEXT NOT NULL,
+  UNIQUE(host_id, mac),
+  FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_host_macs_mac ON host_macs(mac);
+`,
+	},
+	{
+		Version: 13,
+		Name:    "server version",
+		Up: `
+-- 服务端版本行（"SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5"），host key 验证通过后记录
+ALTER TABLE hosts ADD COLUMN server_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE hosts ADD COLUMN server_version_at TEXT NOT NULL DEFAULT '';
+`,
+	},
+}
+
+// Migrations returns all known migrations in order.
+func Migrations() []Migration {
+	out := make([]Migration, len(migrations))
+	copy(out, migrations)
+	return out
+}
+
+// CurrentVersion returns the highest applied migration version (0 for a fresh db).
+func CurrentVersion(db *sql.DB) (int, error) {
+	var v sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&v); err != nil {
+		return 0, err
+	}
+	return int(v.Int64), nil
+}
+
+// Applied returns version -> applied_at for every recorded migration.
+func Applied(db *sql.DB) (map[int]string, error) {
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]string{}
+	for rows.Next() {
+		var v int
+		var at string
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		out[v] = at
+	}
+	return out, rows.Err()
+}
+
+// Pending returns the migrations newer than the current version.
+func Pending(db *sql.DB) ([]Migration, error) {
+	cur, err := CurrentVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, m := range migrations {
+		if m.Version > cur {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// HasData reports whether the db already holds sshmgr tables (i.e. is worth backing up).
+func HasData(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(1) FROM sqlite_master WHERE type='table' AND name='hosts'`).Scan(&n)
+	return n > 0, err
+}
+
+// Backup writes a consistent copy of the database to path.
+func Backup(db *sql.DB, path string) error {
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("backup to %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// Migrate applies all pending migrations, each in its own transaction.
+func Migrate(db *sql.DB) ([]Migration, error) {
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		if err := apply(db, m); err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(m.Up) != "" {
+		if _, err := tx.Exec(m.Up); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version(version,name,applied_at) VALUES(?,?,?)`,
+		m.Version, m.Name, NowUTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,354 @@
+// Package mdns is a small multicast DNS / DNS-SD browser (RFC 6762, RFC 6763).
+//
+// It sends one-shot queries from an ephemeral port, so responders answer by
+// unicast and we never need to bind 5353 or compete with the OS daemon.
+package mdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+// DefaultAddr is the IPv4 mDNS group.
+var DefaultAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// qu 位：请求单播回复（RFC 6762 §5.4）
+const classQU = dnsmessage.Class(0x8000)
+
+// Entry is one resolved service instance.
+type Entry struct {
+	Instance string // e.g. "Mac mini"
+	Service  string // e.g. "_ssh._tcp"
+	Domain   string // e.g. "local."
+	Host     string // SRV target without trailing dot, e.g. "Mac-mini.local"
+	Port     int
+	IPs      []net.IP
+	Text     []string
+}
+
+// Client sends queries to Addr (DefaultAddr when nil). Tests can point Addr at
+// an in-process responder listening on loopback.
+type Client struct {
+	Addr *net.UDPAddr
+
+	// Interfaces to send multicast queries on; empty means the one the OS
+	// routes the group to. Answers come back by unicast on any of them.
+	Interfaces []net.Interface
+}
+
+// Browse queries PTR records for service (e.g. "_ssh._tcp") in domain
+// (e.g. "local.") and streams every instance once its SRV record and, if
+// possible, an address are known. Instances that never got an address are
+// flushed when ctx ends. The channel closes when ctx is done.
+func (c *Client) Browse(ctx context.Context, service, domain string) (<-chan Entry, error) {
+	addr := c.Addr
+	if addr == nil {
+		addr = DefaultAddr
+	}
+	if domain == "" {
+		domain = "local."
+	}
+	domain = fqdn(domain)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &browser{
+		conn:    conn,
+		addr:    addr,
+		service: strings.Trim(service, "."),
+		domain:  domain,
+		ifaces:  c.Interfaces,
+		cache:   newCache(),
+		out:     make(chan Entry),
+		emitted: map[string]bool{},
+	}
+	go b.run(ctx)
+	return b.out, nil
+}
+
+// Browse is Client{}.Browse with the default multicast group.
+func Browse(ctx context.Context, service, domain string) (<-chan Entry, error) {
+	return (&Client{}).Browse(ctx, service, domain)
+}
+
+type browser struct {
+	conn    *net.UDPConn
+	addr    *net.UDPAddr
+	service string
+	domain  string
+	ifaces  []net.Interface
+
+	cache   *cache
+	out     chan Entry
+	emitted map[string]bool
+}
+
+func (b *browser) ptrName() string { return b.service + "." + b.domain }
+
+func (b *browser) run(ctx context.Context) {
+	defer close(b.out)
+
+	var wg sync.WaitGroup
+	packets := make(chan []byte)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(packets)
+		buf := make([]byte, 9000)
+		for {
+			n, _, err := b.conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			pkt := make([]byte, n)
+			copy(pkt, buf[:n])
+			select {
+			case packets <- pkt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	defer wg.Wait()
+	defer b.conn.Close()
+
+	b.query(question(b.ptrName(), dnsmessage.TypePTR))
+
+	// 按 1s,2s,4s... 重发，与 RFC 6762 §5.2 的持续查询节奏一致
+	interval := time.Second
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.flush()
+			return
+		case <-timer.C:
+			b.query(question(b.ptrName(), dnsmessage.TypePTR))
+			b.followUp()
+			interval *= 2
+			timer.Reset(interval)
+		case pkt, ok := <-packets:
+			if !ok {
+				<-ctx.Done()
+				b.flush()
+				return
+			}
+			if err := b.cache.add(pkt); err != nil {
+				continue
+			}
+			if !b.emitReady(ctx) {
+				return
+			}
+			b.followUp()
+		}
+	}
+}
+
+// followUp 对只拿到 PTR 的实例补查 SRV/TXT，对只拿到 SRV 的主机补查 A/AAAA。
+func (b *browser) followUp() {
+	var qs []dnsmessage.Question
+	for _, inst := range b.cache.instances(b.ptrName()) {
+		srv, ok := b.cache.srv[key(inst)]
+		if !ok {
+			qs = append(qs, question(inst, dnsmessage.TypeSRV), question(inst, dnsmessage.TypeTXT))
+			continue
+		}
+		if len(b.cache.addrs[key(srv.host)]) == 0 {
+			qs = append(qs, question(srv.host, dnsmessage.TypeA), question(srv.host, dnsmessage.TypeAAAA))
+		}
+	}
+	if len(qs) > 0 {
+		b.query(qs...)
+	}
+}
+
+func (b *browser) emitReady(ctx context.Context) bool {
+	for _, inst := range b.cache.instances(b.ptrName()) {
+		e, ok := b.entry(inst)
+		if !ok || len(e.IPs) == 0 || b.emitted[key(inst)] {
+			continue
+		}
+		select {
+		case b.out <- e:
+			b.emitted[key(inst)] = true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// flush 在结束时把有 SRV 但没地址的实例也交出去，由调用方自行解析。
+// 此时 ctx 已结束，调用方仍在读 channel 直到关闭，所以这里直接发送。
+func (b *browser) flush() {
+	for _, inst := range b.cache.instances(b.ptrName()) {
+		e, ok := b.entry(inst)
+		if !ok || b.emitted[key(inst)] {
+			continue
+		}
+		b.out <- e
+		b.emitted[key(inst)] = true
+	}
+}
+
+func (b *browser) entry(inst string) (Entry, bool) {
+	srv, ok := b.cache.srv[key(inst)]
+	if !ok {
+		return Entry{}, false
+	}
+	return Entry{
+		Instance: trimSuffixFold(inst, "."+b.ptrName()),
+		Service:  b.service,
+		Domain:   b.domain,
+		Host:     strings.TrimSuffix(srv.host, "."),
+		Port:     srv.port,
+		IPs:      b.cache.addrs[key(srv.host)],
+		Text:     b.cache.txt[key(inst)],
+	}, true
+}
+
+func (b *browser) query(qs ...dnsmessage.Question) {
+	pkt, err := buildQuery(qs...)
+	if err != nil {
+		return
+	}
+	if len(b.ifaces) == 0 {
+		_, _ = b.conn.WriteToUDP(pkt, b.addr)
+		return
+	}
+	pc := ipv4.NewPacketConn(b.conn)
+	for i := range b.ifaces {
+		if err := pc.SetMulticastInterface(&b.ifaces[i]); err != nil {
+			continue
+		}
+		_, _ = b.conn.WriteToUDP(pkt, b.addr)
+	}
+}
+
+func question(name string, t dnsmessage.Type) dnsmessage.Question {
+	return dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(fqdn(name)),
+		Type:  t,
+		Class: dnsmessage.ClassINET | classQU,
+	}
+}
+
+func buildQuery(qs ...dnsmessage.Question) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range qs {
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+func fqdn(s string) string {
+	if strings.HasSuffix(s, ".") {
+		return s
+	}
+	return s + "."
+}
+
+// DNS 名字大小写不敏感
+func key(name string) string { return strings.ToLower(fqdn(name)) }
+
+func trimSuffixFold(s, suffix string) string {
+	if len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix) {
+		return s[:len(s)-len(suffix)]
+	}
+	return s
+}
+
+type srvRecord struct {
+	host string
+	port int
+}
+
+// cache 收集所有响应里的记录；mDNS 响应常把 SRV/TXT/A 放在 additional 段一起带回。
+type cache struct {
+	ptr   map[string][]string // service -> instance full names (original case)
+	srv   map[string]srvRecord
+	txt   map[string][]string
+	addrs map[string][]net.IP
+}
+
+func newCache() *cache {
+	return &cache{
+		ptr:   map[string][]string{},
+		srv:   map[string]srvRecord{},
+		txt:   map[string][]string{},
+		addrs: map[string][]net.IP{},
+	}
+}
+
+var errNotResponse = errors.New("mdns: not a response")
+
+func (c *cache) add(pkt []byte) error {
+	var m dnsmessage.Message
+	if err := m.Unpack(pkt); err != nil {
+		return err
+	}
+	if !m.Header.Response {
+		return errNotResponse
+	}
+
+	rrs := append(append(m.Answers, m.Authorities...), m.Additionals...)
+	for _, rr := range rrs {
+		name := rr.Header.Name.String()
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			inst := body.PTR.String()
+			if !containsFold(c.ptr[key(name)], inst) {
+				c.ptr[key(name)] = append(c.ptr[key(name)], inst)
+			}
+		case *dnsmessage.SRVResource:
+			c.srv[key(name)] = srvRecord{host: body.Target.String(), port: int(body.Port)}
+		case *dnsmessage.TXTResource:
+			c.txt[key(name)] = body.TXT
+		case *dnsmessage.AResource:
+			c.addIP(name, net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			c.addIP(name, net.IP(body.AAAA[:]))
+		}
+	}
+	return nil
+}
+
+func (c *cache) addIP(name string, ip net.IP) {
+	for _, have := range c.addrs[key(name)] {
+		if have.Equal(ip) {
+			return
+		}
+	}
+	c.addrs[key(name)] = append(c.addrs[key(name)], ip)
+}
+
+func (c *cache) instances(ptrName string) []string {
+	return c.ptr[key(ptrName)]
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package mdns
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeResponder 在 loopback 上按问题逐条应答，只回答被问到的记录，
+// 这样浏览器必须自己补查 SRV/TXT 和 A/AAAA。名字用和查询不同的大小写。
+type fakeResponder struct {
+	conn *net.UDPConn
+
+	mu    sync.Mutex
+	asked map[dnsmessage.Type]int
+}
+
+func newFakeResponder(t *testing.T) *fakeResponder {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeResponder{conn: conn, asked: map[dnsmessage.Type]int{}}
+	go r.serve()
+	t.Cleanup(func() { conn.Close() })
+	return r
+}
+
+func (r *fakeResponder) addr() *net.UDPAddr { return r.conn.LocalAddr().(*net.UDPAddr) }
+
+func (r *fakeResponder) count(t dnsmessage.Type) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.asked[t]
+}
+
+func (r *fakeResponder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var m dnsmessage.Message
+		if err := m.Unpack(buf[:n]); err != nil || m.Header.Response {
+			continue
+		}
+		var answers []dnsmessage.Resource
+		for _, q := range m.Questions {
+			r.mu.Lock()
+			r.asked[q.Type]++
+			r.mu.Unlock()
+			answers = append(answers, answer(q)...)
+		}
+		if len(answers) == 0 {
+			continue
+		}
+		resp := dnsmessage.Message{Header: dnsmessage.Header{Response: true, Authoritative: true}, Answers: answers}
+		pkt, err := resp.Pack()
+		if err != nil {
+			continue
+		}
+		_, _ = r.conn.WriteToUDP(pkt, from)
+	}
+}
+
+func rr(name string, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(name), Class: dnsmessage.ClassINET, TTL: 120},
+		Body:   body,
+	}
+}
+
+func answer(q dnsmessage.Question) []dnsmessage.Resource {
+	name := strings.ToLower(q.Name.String())
+	switch {
+	case q.Type == dnsmessage.TypePTR && name == "_ssh._tcp.local.":
+		return []dnsmessage.Resource{
+			rr("_SSH._TCP.Local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("Mac mini._SSH._TCP.Local.")}),
+			rr("_ssh._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("printer._ssh._tcp.local.")}),
+		}
+	case q.Type == dnsmessage.TypeSRV && name == "mac mini._ssh._tcp.local.":
+		return []dnsmessage.Resource{
+			rr("MAC MINI._ssh._tcp.local.", &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("Mac-mini.local."), Port: 2222}),
+		}
+	case q.Type == dnsmessage.TypeTXT && name == "mac mini._ssh._tcp.local.":
+		return []dnsmessage.Resource{
+			rr("mac mini._ssh._tcp.local.", &dnsmessage.TXTResource{TXT: []string{"model=Macmini9,1"}}),
+		}
+	case q.Type == dnsmessage.TypeA && name == "mac-mini.local.":
+		return []dnsmessage.Resource{
+			rr("MAC-MINI.LOCAL.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}}),
+		}
+	case q.Type == dnsmessage.TypeAAAA && name == "mac-mini.local.":
+		return []dnsmessage.Resource{
+			rr("mac-mini.local.", &dnsmessage.AAAAResource{AAAA: [16]byte{0xfe, 0x80, 15: 1}}),
+		}
+	case q.Type == dnsmessage.TypeSRV && name == "printer._ssh._tcp.local.":
+		// 地址永远查不到，只能在结束时交出
+		return []dnsmessage.Resource{
+			rr("printer._ssh._tcp.local.", &dnsmessage.SRVResource{Target: dnsmessage.MustNewName("printer.local."), Port: 22}),
+		}
+	}
+	return nil
+}
+
+func TestBrowseFollowUpAndFlush(t *testing.T) {
+	r := newFakeResponder(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	ch, err := (&Client{Addr: r.addr()}).Browse(ctx, "_ssh._tcp", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]Entry{}
+	afterDone := map[string]bool{}
+	for e := range ch {
+		got[e.Instance] = e
+		afterDone[e.Instance] = ctx.Err() != nil
+	}
+
+	mac, ok := got["Mac mini"]
+	if !ok {
+		t.Fatalf("Mac mini not found, got %v", got)
+	}
+	if mac.Host != "Mac-mini.local" || mac.Port != 2222 || mac.Service != "_ssh._tcp" || mac.Domain != "local." {
+		t.Errorf("Mac mini = %+v", mac)
+	}
+	var ips []string
+	for _, ip := range mac.IPs {
+		ips = append(ips, ip.String())
+	}
+	sort.Strings(ips)
+	if strings.Join(ips, ",") != "192.0.2.10,fe80::1" {
+		t.Errorf("Mac mini IPs = %v", ips)
+	}
+	if len(mac.Text) != 1 || mac.Text[0] != "model=Macmini9,1" {
+		t.Errorf("Mac mini TXT = %v", mac.Text)
+	}
+	if afterDone["Mac mini"] {
+		t.Error("Mac mini was resolved but only emitted at the end of the browse")
+	}
+
+	p, ok := got["printer"]
+	if !ok {
+		t.Fatalf("printer (SRV without address) not flushed, got %v", got)
+	}
+	if p.Host != "printer.local" || p.Port != 22 || len(p.IPs) != 0 {
+		t.Errorf("printer = %+v", p)
+	}
+	if !afterDone["printer"] {
+		t.Error("printer has no address and should only be emitted by the final flush")
+	}
+	if len(got) != 2 {
+		t.Errorf("got %d entries, want 2: %v", len(got), got)
+	}
+
+	for _, typ := range []dnsmessage.Type{dnsmessage.TypeSRV, dnsmessage.TypeTXT, dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		if r.count(typ) == 0 {
+			t.Errorf("browser never asked for %v", typ)
+		}
+	}
+}
+
+func TestCacheIgnoresQueriesAndFoldsCase(t *testing.T) {
+	c := newCache()
+	q, err := buildQuery(question("_ssh._tcp.local", dnsmessage.TypePTR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add(q); err != errNotResponse {
+		t.Fatalf("add(query) = %v, want errNotResponse", err)
+	}
+
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true},
+		Answers: []dnsmessage.Resource{
+			rr("_ssh._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("a._ssh._tcp.local.")}),
+			rr("_SSH._tcp.local.", &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName("A._SSH._TCP.LOCAL.")}),
+			rr("Host.local.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+			rr("HOST.local.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+		},
+	}
+	pkt, err := resp.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.add(pkt); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.instances("_SSH._TCP.LOCAL."); len(got) != 1 {
+		t.Errorf("instances = %v, want one (PTR targets differ only in case)", got)
+	}
+	if got := c.addrs[key("host.LOCAL")]; len(got) != 1 {
+		t.Errorf("addrs = %v, want one", got)
+	}
+}
+
+func TestTrimSuffixFold(t *testing.T) {
+	tests := []struct{ s, suffix, want string }{
+		{"Mac mini._ssh._tcp.local.", "._ssh._tcp.local.", "Mac mini"},
+		{"Mac mini._SSH._TCP.Local.", "._ssh._tcp.local.", "Mac mini"},
+		{"other", "._ssh._tcp.local.", "other"},
+	}
+	for _, tt := range tests {
+		if got := trimSuffixFold(tt.s, tt.suffix); got != tt.want {
+			t.Errorf("trimSuffixFold(%q, %q) = %q, want %q", tt.s, tt.suffix, got, tt.want)
+		}
+	}
+}
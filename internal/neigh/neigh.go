@@ -0,0 +1,185 @@
+// Package neigh reads the kernel's neighbor table (ARP for IPv4, NDP for
+// IPv6): which IP addresses on the local links answer with which MAC.
+//
+// On Linux the table comes from netlink, falling back to /proc/net/arp
+// (IPv4 only); on macOS and the BSDs from `arp -an` and `ndp -an`; on
+// Windows from `arp -a` (IPv4 only). Incomplete, failed and multicast
+// entries are left out.
+package neigh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Entry is one neighbor.
+type Entry struct {
+	IP    netip.Addr // link-local IPv6 addresses carry their zone
+	MAC   string     // lower case, colon separated, e.g. 00:11:22:aa:bb:cc
+	Iface string     // may be empty on Windows
+}
+
+// Read returns the current neighbor table.
+func Read(ctx context.Context) ([]Entry, error) {
+	return read(ctx)
+}
+
+// ByMAC returns the entries whose MAC is one of macs, most specific first:
+// IPv4 before IPv6, so callers try the address they would scan anyway.
+func ByMAC(entries []Entry, macs []string) []Entry {
+	want := map[string]bool{}
+	for _, m := range macs {
+		if m = NormalizeMAC(m); m != "" {
+			want[m] = true
+		}
+	}
+	var v4, v6 []Entry
+	for _, e := range entries {
+		if !want[e.MAC] {
+			continue
+		}
+		if e.IP.Is4() {
+			v4 = append(v4, e)
+		} else {
+			v6 = append(v6, e)
+		}
+	}
+	return append(v4, v6...)
+}
+
+// ByIP returns the entry for ip, ignoring the zone.
+func ByIP(entries []Entry, ip string) (Entry, bool) {
+	a, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Entry{}, false
+	}
+	a = a.Unmap().WithZone("")
+	for _, e := range entries {
+		if e.IP.WithZone("") == a {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// NormalizeMAC turns aa-bb-cc-dd-ee-ff, AA:BB:... and macOS's short form
+// 0:11:2:... into 00:11:02:...; it returns "" for anything that is not a
+// unicast 48-bit MAC.
+func NormalizeMAC(s string) string {
+	parts := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 {
+		return ""
+	}
+	var b [6]byte
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 16, 8)
+		if err != nil || len(p) > 2 {
+			return ""
+		}
+		b[i] = byte(v)
+	}
+	// 全 0 是未完成的表项，最低位为 1 是组播/广播
+	if b == [6]byte{} || b[0]&1 != 0 {
+		return ""
+	}
+	return fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", b[0], b[1], b[2], b[3], b[4], b[5])
+}
+
+func entry(ip, mac, iface string) (Entry, bool) {
+	a, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Entry{}, false
+	}
+	m := NormalizeMAC(mac)
+	if m == "" {
+		return Entry{}, false
+	}
+	a = a.Unmap()
+	if a.Is6() && a.IsLinkLocalUnicast() && a.Zone() == "" && iface != "" {
+		a = a.WithZone(iface)
+	}
+	return Entry{IP: a, MAC: m, Iface: iface}, true
+}
+
+// parseProcARP 解析 /proc/net/arp：
+// IP address  HW type  Flags  HW address         Mask  Device
+// 192.0.2.1   0x1      0x2    00:11:22:33:44:55  *     eth0
+func parseProcARP(out []byte) []Entry {
+	var list []Entry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 6 || f[2] == "0x0" {
+			continue
+		}
+		if e, ok := entry(f[0], f[3], f[5]); ok {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parseARPAn 解析 BSD/macOS 的 `arp -an`：
+// ? (192.0.2.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]
+func parseARPAn(out []byte) []Entry {
+	var list []Entry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 4 || f[2] != "at" {
+			continue
+		}
+		iface := ""
+		for i := 4; i+1 < len(f); i++ {
+			if f[i] == "on" {
+				iface = f[i+1]
+			}
+		}
+		if e, ok := entry(strings.Trim(f[1], "()"), f[3], iface); ok {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parseNDP 解析 `ndp -an`：
+// fe80::1%en0  0:11:22:33:44:55  en0  23h59m58s S R
+func parseNDP(out []byte) []Entry {
+	var list []Entry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 3 {
+			continue
+		}
+		if e, ok := entry(f[0], f[1], f[2]); ok { // 表头和 (incomplete) 会被跳过
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// parseWinARP 解析 Windows 的 `arp -a`：
+// Interface: 192.0.2.10 --- 0xb
+//
+//	Internet Address      Physical Address      Type
+//	192.0.2.1             00-11-22-33-44-55     dynamic
+func parseWinARP(out []byte) []Entry {
+	var list []Entry
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 3 {
+			continue
+		}
+		if e, ok := entry(f[0], f[1], ""); ok {
+			list = append(list, e)
+		}
+	}
+	return list
+}
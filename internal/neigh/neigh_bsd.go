@@ -0,0 +1,22 @@
+//go:build !linux && !windows
+
+package neigh
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+func read(ctx context.Context) ([]Entry, error) {
+	out, err := exec.CommandContext(ctx, "arp", "-an").Output()
+	if err != nil {
+		return nil, errors.New("reading the ARP table needs `arp`")
+	}
+	list := parseARPAn(out)
+	// 没有 ndp 时只有 IPv4
+	if out, err := exec.CommandContext(ctx, "ndp", "-an").Output(); err == nil {
+		list = append(list, parseNDP(out)...)
+	}
+	return list, nil
+}
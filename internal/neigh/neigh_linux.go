@@ -0,0 +1,105 @@
+//go:build linux
+
+package neigh
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+)
+
+const (
+	ndaDst    = 1 // NDA_DST
+	ndaLLAddr = 2 // NDA_LLADDR
+
+	nudIncomplete = 0x01
+	nudFailed     = 0x20
+	nudNoARP      = 0x40
+
+	ndmsgLen = 12 // struct ndmsg
+)
+
+func read(ctx context.Context) ([]Entry, error) {
+	list, err := readNetlink()
+	if err == nil {
+		return list, nil
+	}
+	// 没有 netlink 权限（部分容器）时退回只有 IPv4 的 /proc/net/arp
+	b, perr := os.ReadFile("/proc/net/arp")
+	if perr != nil {
+		return nil, err
+	}
+	return parseProcARP(b), nil
+}
+
+// readNetlink 用 RTM_GETNEIGH 取 IPv4 和 IPv6 的邻居表
+func readNetlink() ([]Entry, error) {
+	tab, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, os.NewSyscallError("netlinkrib", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(tab)
+	if err != nil {
+		return nil, os.NewSyscallError("parsenetlinkmessage", err)
+	}
+
+	names := map[int32]string{}
+	ifname := func(idx int32) string {
+		if n, ok := names[idx]; ok {
+			return n
+		}
+		n := ""
+		if ifi, err := net.InterfaceByIndex(int(idx)); err == nil {
+			n = ifi.Name
+		}
+		names[idx] = n
+		return n
+	}
+
+	var list []Entry
+	for _, m := range msgs {
+		if m.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < ndmsgLen {
+			continue
+		}
+		idx := int32(binary.NativeEndian.Uint32(m.Data[4:8]))
+		state := binary.NativeEndian.Uint16(m.Data[8:10])
+		if state&(nudIncomplete|nudFailed|nudNoARP) != 0 {
+			continue
+		}
+
+		var dst net.IP
+		var lladdr net.HardwareAddr
+		attrs := m.Data[ndmsgLen:]
+		for len(attrs) >= syscall.SizeofRtAttr {
+			alen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+			atype := binary.NativeEndian.Uint16(attrs[2:4])
+			if alen < syscall.SizeofRtAttr || alen > len(attrs) {
+				break
+			}
+			v := attrs[syscall.SizeofRtAttr:alen]
+			switch atype {
+			case ndaDst:
+				dst = net.IP(v)
+			case ndaLLAddr:
+				lladdr = net.HardwareAddr(v)
+			}
+			n := (alen + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+			if n > len(attrs) {
+				break
+			}
+			attrs = attrs[n:]
+		}
+		if dst == nil || len(lladdr) != 6 {
+			continue
+		}
+		if e, ok := entry(dst.String(), lladdr.String(), ifname(idx)); ok {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
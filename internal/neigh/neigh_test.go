@@ -0,0 +1,116 @@
+package neigh
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeMAC(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"00:11:22:aa:bb:cc", "00:11:22:aa:bb:cc"},
+		{"00-11-22-AA-BB-CC", "00:11:22:aa:bb:cc"},
+		{"0:11:2:a:b:c", "00:11:02:0a:0b:0c"},
+		{"00:00:00:00:00:00", ""}, // 未完成的表项
+		{"ff:ff:ff:ff:ff:ff", ""}, // 广播
+		{"01:00:5e:00:00:fb", ""}, // 组播
+		{"33:33:00:00:00:01", ""},
+		{"00:11:22:33:44", ""},
+		{"00:11:22:33:44:55:66", ""},
+		{"000:11:22:33:44:55", ""},
+		{"00:11:22:33:44:zz", ""},
+		{"(incomplete)", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeMAC(tt.in); got != tt.want {
+			t.Errorf("NormalizeMAC(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func e(ip, mac, iface string) Entry {
+	return Entry{IP: netip.MustParseAddr(ip), MAC: mac, Iface: iface}
+}
+
+func TestParsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) []Entry
+		out   string
+		want  []Entry
+	}{
+		{"proc", parseProcARP, `IP address       HW type     Flags       HW address            Mask     Device
+192.0.2.1        0x1         0x2         00:11:22:33:44:55     *        eth0
+192.0.2.9        0x1         0x0         00:00:00:00:00:00     *        eth0
+192.0.2.20       0x1         0x2         AA:BB:CC:DD:EE:F0     *        wlan0
+`, []Entry{e("192.0.2.1", "00:11:22:33:44:55", "eth0"), e("192.0.2.20", "aa:bb:cc:dd:ee:f0", "wlan0")}},
+
+		{"arp -an", parseARPAn, `? (192.0.2.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]
+? (192.0.2.7) at (incomplete) on en0 ifscope [ethernet]
+? (192.0.2.255) at ff:ff:ff:ff:ff:ff on en0 ifscope [ethernet]
+? (224.0.0.251) at 1:0:5e:0:0:fb on en0 ifscope permanent [ethernet]
+nas.lan (192.0.2.30) at a:b:c:d:e:f on bge0 expires in 1183 seconds [ethernet]
+`, []Entry{e("192.0.2.1", "00:11:22:33:44:55", "en0"), e("192.0.2.30", "0a:0b:0c:0d:0e:0f", "bge0")}},
+
+		{"ndp -an", parseNDP, `Neighbor                        Linklayer Address  Netif Expire    S Flags
+fe80::1%en0                     0:11:22:33:44:55     en0 23h59m58s S R
+fe80::2                         0:11:22:33:44:66     en0 5s        R
+fd00::7                         (incomplete)         en0 expired   N
+fd00::8                         a:b:c:d:e:f          en1 permanent R
+`, []Entry{
+			e("fe80::1%en0", "00:11:22:33:44:55", "en0"),
+			e("fe80::2%en0", "00:11:22:33:44:66", "en0"), // 链路本地地址补上接口
+			e("fd00::8", "0a:0b:0c:0d:0e:0f", "en1"),
+		}},
+
+		{"windows arp -a", parseWinARP, `
+Interface: 192.0.2.10 --- 0xb
+  Internet Address      Physical Address      Type
+  192.0.2.1             00-11-22-33-44-55     dynamic
+  192.0.2.255           ff-ff-ff-ff-ff-ff     static
+  224.0.0.22            01-00-5e-00-00-16     static
+`, []Entry{e("192.0.2.1", "00:11:22:33:44:55", "")}},
+	}
+	for _, tt := range tests {
+		if got := tt.parse([]byte(tt.out)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestByMACAndByIP(t *testing.T) {
+	entries := []Entry{
+		e("fe80::1%en0", "00:11:22:33:44:55", "en0"),
+		e("192.0.2.1", "00:11:22:33:44:55", "en0"),
+		e("192.0.2.2", "00:11:22:33:44:66", "en0"),
+	}
+	got := ByMAC(entries, []string{"00-11-22-33-44-55", "not a mac"})
+	if want := []Entry{entries[1], entries[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ByMAC = %v, want IPv4 first %v", got, want)
+	}
+
+	tests := []struct {
+		ip   string
+		want int // entries 下标，-1 为找不到
+	}{
+		{"192.0.2.2", 2},
+		{"::ffff:192.0.2.1", 1},
+		{"fe80::1", 0},
+		{"fe80::1%en1", 0},
+		{"192.0.2.3", -1},
+		{"bogus", -1},
+	}
+	for _, tt := range tests {
+		got, ok := ByIP(entries, tt.ip)
+		if tt.want < 0 {
+			if ok {
+				t.Errorf("ByIP(%s) = %v, want none", tt.ip, got)
+			}
+			continue
+		}
+		if !ok || got != entries[tt.want] {
+			t.Errorf("ByIP(%s) = %v %v, want %v", tt.ip, got, ok, entries[tt.want])
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package neigh
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+func read(ctx context.Context) ([]Entry, error) {
+	out, err := exec.CommandContext(ctx, "arp", "-a").Output()
+	if err != nil {
+		return nil, errors.New("reading the ARP table needs `arp`")
+	}
+	return parseWinARP(out), nil
+}
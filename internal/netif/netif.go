@@ -0,0 +1,189 @@
+// Package netif lists the local network interfaces and derives the LANs
+// worth scanning from them.
+//
+// By default only IPv4 networks on interfaces that are up are used.
+// Loopback, point-to-point links and tunnels (VPNs, utun, wg), and the
+// bridges of container and VM software (docker, veth, virbr, vmnet...) are
+// skipped, and so are IPv4 link-local addresses. Networks larger than a /22
+// are narrowed to the /24 around this machine's address.
+package netif
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// MinScanBits is the largest network (smallest prefix length) scanned as a
+// whole; bigger IPv4 networks are narrowed to the local /24.
+const MinScanBits = 22
+
+// Network is one address configured on a local interface.
+type Network struct {
+	Iface  string
+	Index  int
+	Addr   netip.Addr   // this machine's address; invalid if the interface has none
+	Prefix netip.Prefix // the configured network
+	Scan   netip.Prefix // what is scanned: Prefix, or the narrowed /24
+	Skip   string       // why it is not used by default; "" when used
+}
+
+// Policy selects networks.
+type Policy struct {
+	// Ifaces restricts the result to these interfaces. Naming an interface
+	// also overrides the tunnel and virtual skips for it.
+	Ifaces []string
+	IPv6   bool // include global and unique local IPv6 prefixes
+}
+
+// 容器、虚拟机软件建的网桥，以及苹果的点对点无线接口
+var virtualPrefixes = []string{
+	"docker", "br-", "veth", "virbr", "vmnet", "vboxnet", "cni", "flannel", "cali",
+	"lxcbr", "lxdbr", "podman", "kube", "weave", "vethernet", "bridge", "awdl", "llw", "anpi",
+}
+
+// VPN 和隧道
+var tunnelPrefixes = []string{
+	"tun", "tap", "utun", "wg", "tailscale", "zt", "ipsec", "ppp", "gif", "stf", "nordlynx",
+}
+
+// List returns every address of every interface, with Skip explaining the
+// ones p does not use.
+func List(p Policy) ([]Network, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	chosen := map[string]bool{}
+	for _, name := range p.Ifaces {
+		chosen[name] = true
+	}
+
+	var out []Network
+	for _, ifi := range ifaces {
+		skip := ifaceSkip(ifi)
+		if len(chosen) > 0 {
+			switch {
+			case !chosen[ifi.Name]:
+				skip = "not selected"
+			case skip != "down":
+				skip = ""
+			}
+		}
+
+		addrs, _ := ifi.Addrs()
+		n := 0
+		for _, a := range addrs {
+			ipn, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip, ok := netip.AddrFromSlice(ipn.IP)
+			if !ok {
+				continue
+			}
+			ip = ip.Unmap()
+			bits, _ := ipn.Mask.Size()
+			nw := Network{
+				Iface:  ifi.Name,
+				Index:  ifi.Index,
+				Addr:   ip,
+				Prefix: netip.PrefixFrom(ip, bits).Masked(),
+				Skip:   skip,
+			}
+			nw.Scan = nw.Prefix
+			if nw.Skip == "" {
+				nw.Skip = addrSkip(ip, bits, p)
+			}
+			if ip.Is4() && bits < MinScanBits {
+				nw.Scan = netip.PrefixFrom(ip, 24).Masked()
+			}
+			out = append(out, nw)
+			n++
+		}
+		if n == 0 && ifi.Flags&net.FlagUp != 0 {
+			if skip == "" {
+				skip = "no address"
+			}
+			out = append(out, Network{Iface: ifi.Name, Index: ifi.Index, Skip: skip})
+		}
+	}
+	return out, nil
+}
+
+// Subnets returns the networks to scan under p, without duplicates.
+func Subnets(p Policy) ([]Network, error) {
+	all, err := List(p)
+	if err != nil {
+		return nil, err
+	}
+	var out []Network
+	seen := map[netip.Prefix]bool{}
+	for _, n := range all {
+		if n.Skip != "" || seen[n.Scan] {
+			continue
+		}
+		seen[n.Scan] = true
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// Interfaces returns the interfaces that have at least one network used
+// under p, e.g. to send multicast queries on.
+func Interfaces(p Policy) ([]net.Interface, error) {
+	nets, err := Subnets(p)
+	if err != nil {
+		return nil, err
+	}
+	var out []net.Interface
+	seen := map[int]bool{}
+	for _, n := range nets {
+		if seen[n.Index] {
+			continue
+		}
+		seen[n.Index] = true
+		if ifi, err := net.InterfaceByIndex(n.Index); err == nil {
+			out = append(out, *ifi)
+		}
+	}
+	return out, nil
+}
+
+func ifaceSkip(ifi net.Interface) string {
+	name := strings.ToLower(ifi.Name)
+	switch {
+	case ifi.Flags&net.FlagUp == 0:
+		return "down"
+	case ifi.Flags&net.FlagLoopback != 0:
+		return "loopback"
+	case ifi.Flags&net.FlagPointToPoint != 0 || hasPrefix(name, tunnelPrefixes):
+		return "tunnel"
+	case hasPrefix(name, virtualPrefixes):
+		return "virtual"
+	}
+	return ""
+}
+
+func addrSkip(ip netip.Addr, bits int, p Policy) string {
+	switch {
+	case ip.IsLoopback():
+		return "loopback"
+	case ip.IsLinkLocalUnicast():
+		return "link-local"
+	case ip.Is6() && !p.IPv6:
+		return "ipv6 (use --ipv6)"
+	case ip.Is4() && bits >= 31, ip.Is6() && bits >= 127:
+		return "single address"
+	}
+	return ""
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,35 +1,157 @@
 package netutil
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// SHBanner 连接目标 IP 的 22 端口，并读取返回的第一行 SSH 握手信息
-func SSHBanner(ip string, timeout time.Duration) (string, error) {
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, "22"), timeout)
+// Banner is an SSH identification string (RFC 4253 §4.2):
+//
+//	SSH-protoversion-softwareversion SP comments CR LF
+//
+// e.g. "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5".
+type Banner struct {
+	Raw      string   // the identification line without CR LF
+	Proto    string   // "2.0", or "1.99" for servers that also speak SSH-1
+	Software string   // "OpenSSH_9.6p1"
+	Comments string   // "Ubuntu-3ubuntu13.5", may be empty
+	Pre      []string // other lines the server sent first (allowed by the RFC)
+}
+
+// 版本行之前的其他行最多读这么多行/字节
+const (
+	maxBannerLines = 32
+	maxBannerBytes = 16 << 10
+)
+
+// ParseBanner parses one identification line.
+func ParseBanner(line string) (Banner, error) {
+	line = strings.TrimRight(line, "\r\n")
+	rest, ok := strings.CutPrefix(line, "SSH-")
+	if !ok {
+		return Banner{}, fmt.Errorf("not an SSH identification string: %q", line)
+	}
+	proto, rest, ok := strings.Cut(rest, "-")
+	if !ok || proto == "" {
+		return Banner{}, fmt.Errorf("missing protocol version: %q", line)
+	}
+	software, comments, _ := strings.Cut(rest, " ")
+	if software == "" {
+		return Banner{}, fmt.Errorf("missing software version: %q", line)
+	}
+	return Banner{Raw: line, Proto: proto, Software: software, Comments: strings.TrimSpace(comments)}, nil
+}
+
+// ReadBanner reads lines from r until the identification line.
+func ReadBanner(r *bufio.Reader) (Banner, error) {
+	var pre []string
+	for i := 0; i <= maxBannerLines; i++ {
+		line, err := r.ReadString('\n')
+		// 没有换行就断开时，只要是版本行也接受
+		if err != nil && !strings.HasPrefix(line, "SSH-") {
+			if errors.Is(err, io.EOF) {
+				return Banner{}, errors.New("connection closed before the SSH identification string")
+			}
+			return Banner{}, err
+		}
+		if strings.HasPrefix(line, "SSH-") {
+			b, err := ParseBanner(line)
+			b.Pre = pre
+			return b, err
+		}
+		pre = append(pre, strings.TrimRight(line, "\r\n"))
+	}
+	return Banner{}, errors.New("no SSH identification string in the first lines")
+}
+
+// SSHBanner connects to host:port and reads the server's identification
+// string; nothing is sent, so no login attempt is logged.
+func SSHBanner(host string, port int, timeout time.Duration) (Banner, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, strconv.Itoa(port)), timeout)
 	if err != nil {
-		return "", err
+		return Banner{}, err
 	}
 	defer conn.Close()
 
 	_ = conn.SetReadDeadline(time.Now().Add(timeout))
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
-	if err != nil {
-		return "", err
-	}
-	return string(buf[:n]), nil
+	return ReadBanner(bufio.NewReader(io.LimitReader(conn, maxBannerBytes)))
 }
 
-// ExtractHostname 尝试从 SSH Banner 文本中提取形如 "xxx.local" 的主机名。
-func ExtractHostname(banner string) string {
-	fields := strings.Fields(banner)
-	for _, f := range fields {
-		if strings.HasSuffix(f, ".local") {
-			return f
+// 局域网里常见的主机名后缀
+var localSuffixes = []string{".local", ".lan", ".home", ".home.arpa", ".internal", ".localdomain"}
+
+// Hostname 尝试从 comments 和版本行之前的文本（如 "Welcome to nas.lan"）
+// 中提取带局域网后缀的主机名，找不到返回空串。
+func (b Banner) Hostname() string {
+	for _, text := range append([]string{b.Comments}, b.Pre...) {
+		for _, f := range strings.Fields(text) {
+			f = strings.Trim(f, `"'()[]<>,;:!?.`)
+			for _, suf := range localSuffixes {
+				if len(f) > len(suf) && strings.HasSuffix(strings.ToLower(f), suf) {
+					return f
+				}
+			}
 		}
 	}
 	return ""
 }
+
+// OpenSSHVersion is the upstream release of an OpenSSH server, e.g. 9.6p1.
+type OpenSSHVersion struct {
+	Major, Minor, Patch int
+}
+
+var openSSHRe = regexp.MustCompile(`^OpenSSH(?:_for_Windows)?_(\d+)\.(\d+)(?:\.\d+)*(?:p(\d+))?`)
+
+// OpenSSH returns the OpenSSH release in b.Software; ok is false for other
+// implementations (dropbear, libssh...) and unparsable versions.
+func (b Banner) OpenSSH() (v OpenSSHVersion, ok bool) {
+	m := openSSHRe.FindStringSubmatch(b.Software)
+	if m == nil {
+		return v, false
+	}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, true
+}
+
+// ParseOpenSSHVersion parses "10", "9.8" or "9.3p2".
+func ParseOpenSSHVersion(s string) (OpenSSHVersion, error) {
+	if !strings.Contains(s, ".") {
+		s += ".0" // "10" 即 10.0
+	}
+	v, ok := Banner{Software: "OpenSSH_" + s}.OpenSSH()
+	if !ok {
+		return v, fmt.Errorf("invalid OpenSSH version %q (want e.g. 9.8 or 9.3p2)", s)
+	}
+	return v, nil
+}
+
+// Less reports whether v is an older release than w.
+func (v OpenSSHVersion) Less(w OpenSSHVersion) bool {
+	if v.Major != w.Major {
+		return v.Major < w.Major
+	}
+	if v.Minor != w.Minor {
+		return v.Minor < w.Minor
+	}
+	return v.Patch < w.Patch
+}
+
+func (v OpenSSHVersion) String() string {
+	s := fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	if v.Patch > 0 {
+		s += fmt.Sprintf("p%d", v.Patch)
+	}
+	return s
+}
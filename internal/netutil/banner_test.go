@@ -0,0 +1,141 @@
+package netutil
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBanner(t *testing.T) {
+	tests := []struct {
+		line string
+		want Banner
+	}{
+		{"SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5\r\n", Banner{Raw: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13.5", Proto: "2.0", Software: "OpenSSH_9.6p1", Comments: "Ubuntu-3ubuntu13.5"}},
+		{"SSH-2.0-dropbear_2022.83", Banner{Raw: "SSH-2.0-dropbear_2022.83", Proto: "2.0", Software: "dropbear_2022.83"}},
+		{"SSH-1.99-Cisco-1.25\n", Banner{Raw: "SSH-1.99-Cisco-1.25", Proto: "1.99", Software: "Cisco-1.25"}},
+		{"SSH-2.0-OpenSSH_for_Windows_8.1  ", Banner{Raw: "SSH-2.0-OpenSSH_for_Windows_8.1  ", Proto: "2.0", Software: "OpenSSH_for_Windows_8.1"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseBanner(tt.line)
+		if err != nil {
+			t.Errorf("ParseBanner(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseBanner(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{"", "HTTP/1.1 400 Bad Request", "SSH-", "SSH-2.0", "SSH--x", "SSH-2.0- comment"} {
+		if b, err := ParseBanner(line); err == nil {
+			t.Errorf("ParseBanner(%q) = %+v, want error", line, b)
+		}
+	}
+}
+
+func TestReadBanner(t *testing.T) {
+	tests := []struct {
+		data     string
+		software string
+		pre      []string
+		ok       bool
+	}{
+		{"SSH-2.0-OpenSSH_9.6\r\n", "OpenSSH_9.6", nil, true},
+		{"Welcome to nas.lan\r\n\r\nSSH-2.0-dropbear\r\n", "dropbear", []string{"Welcome to nas.lan", ""}, true},
+		// 连接在换行之前断开
+		{"SSH-2.0-OpenSSH_9.6", "OpenSSH_9.6", nil, true},
+		{"", "", nil, false},
+		{"hello\r\n", "", nil, false},
+		{strings.Repeat("x\n", maxBannerLines+1) + "SSH-2.0-late\r\n", "", nil, false},
+	}
+	for _, tt := range tests {
+		b, err := ReadBanner(bufio.NewReader(strings.NewReader(tt.data)))
+		if (err == nil) != tt.ok {
+			t.Errorf("ReadBanner(%q) error = %v, want ok=%v", tt.data, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if b.Software != tt.software || !reflect.DeepEqual(b.Pre, tt.pre) {
+			t.Errorf("ReadBanner(%q) = %+v, want software %q pre %q", tt.data, b, tt.software, tt.pre)
+		}
+	}
+}
+
+func TestHostname(t *testing.T) {
+	tests := []struct {
+		b    Banner
+		want string
+	}{
+		{Banner{Comments: "Ubuntu-3ubuntu13.5"}, ""},
+		{Banner{Comments: "nas.lan"}, "nas.lan"},
+		{Banner{Pre: []string{"Welcome to (Router.Home.Arpa)."}}, "Router.Home.Arpa"},
+		{Banner{Pre: []string{"see .local"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Hostname(); got != tt.want {
+			t.Errorf("%+v.Hostname() = %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestOpenSSH(t *testing.T) {
+	tests := []struct {
+		software string
+		want     OpenSSHVersion
+		ok       bool
+	}{
+		{"OpenSSH_9.6p1", OpenSSHVersion{9, 6, 1}, true},
+		{"OpenSSH_7.4", OpenSSHVersion{7, 4, 0}, true},
+		{"OpenSSH_for_Windows_8.1", OpenSSHVersion{8, 1, 0}, true},
+		{"OpenSSH_8.9.1p2", OpenSSHVersion{8, 9, 2}, true},
+		{"OpenSSH_10.0", OpenSSHVersion{10, 0, 0}, true},
+		{"dropbear_2022.83", OpenSSHVersion{}, false},
+		{"OpenSSH", OpenSSHVersion{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Banner{Software: tt.software}.OpenSSH()
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("OpenSSH(%q) = %v %v, want %v %v", tt.software, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseOpenSSHVersion(t *testing.T) {
+	tests := []struct {
+		s, want string
+		ok      bool
+	}{
+		{"10", "10.0", true},
+		{"9.8", "9.8", true},
+		{"9.3p2", "9.3p2", true},
+		{"", "", false},
+		{"x.y", "", false},
+	}
+	for _, tt := range tests {
+		v, err := ParseOpenSSHVersion(tt.s)
+		if (err == nil) != tt.ok || (tt.ok && v.String() != tt.want) {
+			t.Errorf("ParseOpenSSHVersion(%q) = %v, %v; want %s", tt.s, v, err, tt.want)
+		}
+	}
+}
+
+func TestOpenSSHVersionLess(t *testing.T) {
+	tests := []struct {
+		v, w OpenSSHVersion
+		want bool
+	}{
+		{OpenSSHVersion{9, 3, 1}, OpenSSHVersion{9, 3, 2}, true},
+		{OpenSSHVersion{9, 3, 2}, OpenSSHVersion{9, 3, 2}, false},
+		{OpenSSHVersion{8, 9, 9}, OpenSSHVersion{9, 0, 0}, true},
+		{OpenSSHVersion{9, 10, 0}, OpenSSHVersion{9, 9, 5}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Less(tt.w); got != tt.want {
+			t.Errorf("%v.Less(%v) = %v, want %v", tt.v, tt.w, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package probe inspects an SSH endpoint by doing the handshake itself
+// (golang.org/x/crypto/ssh) instead of running ssh and matching its
+// messages. A probe reports how far it got: TCP, the server's version
+// line, the host key, and the authentication methods offered to a user.
+package probe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"sshmgr/internal/sshutil"
+)
+
+// TCPState is the outcome of the TCP connect.
+type TCPState string
+
+const (
+	TCPOpen        TCPState = "open"
+	TCPRefused     TCPState = "refused"
+	TCPTimeout     TCPState = "timeout"
+	TCPUnreachable TCPState = "unreachable"
+	TCPError       TCPState = "error" // DNS failure and everything else
+)
+
+// Options controls a probe.
+type Options struct {
+	User        string
+	Timeout     time.Duration // whole probe; default 2s
+	DialTimeout time.Duration // TCP connect only; default Timeout
+
+	// Signers are tried with publickey auth; if one is accepted the result
+	// is Authenticated (the connection is closed right after).
+	Signers []ssh.Signer
+
+	// HostKeyAlgorithms asks the server for specific key types, e.g. the
+	// ones already pinned. Empty means the library default.
+	HostKeyAlgorithms []string
+
+	// NoAuth stops right after key exchange: Result has the version and the
+	// host key but no auth information, and the server logs no login attempt.
+	NoAuth bool
+}
+
+// Result is what a probe learned. Fields after TCP are only set when the
+// probe got that far.
+type Result struct {
+	Addr string
+	TCP  TCPState
+	RTT  time.Duration // TCP connect time
+
+	ServerVersion string           // e.g. "SSH-2.0-OpenSSH_9.6"
+	HostKey       *sshutil.HostKey // the key presented during key exchange
+
+	// AuthMethods are the methods the server offered to User (of
+	// publickey, password, keyboard-interactive, gssapi-with-mic).
+	AuthMethods   []string
+	AuthBanner    string // SSH_MSG_USERAUTH_BANNER text, if any
+	Authenticated bool   // "none" or one of Signers was accepted
+	// UserRejected: the server disconnected or offered no method at all
+	// before any credential was sent.
+	UserRejected bool
+
+	Err error // why the probe stopped, nil if it completed
+}
+
+// SSH reports whether the endpoint spoke the SSH protocol.
+func (r Result) SSH() bool { return r.ServerVersion != "" }
+
+// Status summarizes r as OK / AUTH / DENY / DOWN / ERR:
+// logged in, needs credentials, user refused, not reachable, anything else.
+func (r Result) Status() string {
+	switch {
+	case r.TCP == TCPRefused || r.TCP == TCPTimeout || r.TCP == TCPUnreachable:
+		return "DOWN"
+	case r.TCP != TCPOpen || r.HostKey == nil:
+		return "ERR"
+	case r.Authenticated:
+		return "OK"
+	case r.UserRejected:
+		return "DENY"
+	case len(r.AuthMethods) > 0:
+		return "AUTH"
+	}
+	return "ERR"
+}
+
+var errStop = errors.New("probe: stop after key exchange")
+
+// Probe connects to addr (host:port) and reports what it found. It never
+// returns an error itself; failures are in Result.TCP and Result.Err.
+func Probe(ctx context.Context, addr string, opts Options) Result {
+	res := Result{Addr: addr, TCP: TCPError}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	d := net.Dialer{Timeout: opts.DialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		res.TCP, res.Err = tcpState(err), err
+		return res
+	}
+	res.TCP, res.RTT = TCPOpen, time.Since(start)
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	// ctx 取消时让阻塞的读写立刻返回
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	vc := &versionConn{Conn: conn}
+	var mu sync.Mutex
+	offered := map[string]bool{}
+	note := func(m string) {
+		mu.Lock()
+		offered[m] = true
+		mu.Unlock()
+	}
+
+	cfg := &ssh.ClientConfig{
+		User: opts.User,
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			res.HostKey = &sshutil.HostKey{Type: key.Type(), Fingerprint: ssh.FingerprintSHA256(key)}
+			if opts.NoAuth {
+				return errStop
+			}
+			return nil
+		},
+		BannerCallback: func(msg string) error {
+			res.AuthBanner = strings.TrimSpace(msg)
+			return nil
+		},
+		HostKeyAlgorithms: opts.HostKeyAlgorithms,
+		Auth:              probeAuth(opts.Signers, note),
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(vc, addr, cfg)
+	res.ServerVersion = vc.version()
+	if err == nil {
+		res.Authenticated = true
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			for ch := range chans {
+				_ = ch.Reject(ssh.Prohibited, "probe")
+			}
+		}()
+		_ = c.Close()
+	}
+
+	for _, m := range []string{"publickey", "password", "keyboard-interactive", "gssapi-with-mic"} {
+		if offered[m] {
+			res.AuthMethods = append(res.AuthMethods, m)
+		}
+	}
+	switch {
+	case err == nil, opts.NoAuth && res.HostKey != nil:
+	case res.HostKey != nil:
+		// 认证阶段失败：一种方式都没被提供说明服务端在认证前就拒绝了这个用户
+		res.UserRejected = len(res.AuthMethods) == 0
+		res.Err = err
+	default:
+		res.Err = err
+	}
+	return res
+}
+
+// probeAuth 的回调只在服务端提供该方式时才会被调用，借此记下 AuthMethods。
+// 除了 Signers 不发送任何凭据。
+func probeAuth(signers []ssh.Signer, note func(string)) []ssh.AuthMethod {
+	errNoCred := errors.New("probe: no credentials")
+	return []ssh.AuthMethod{
+		ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			note("publickey")
+			return signers, nil
+		}),
+		ssh.PasswordCallback(func() (string, error) {
+			note("password")
+			return "", errNoCred
+		}),
+		ssh.KeyboardInteractive(func(_, _ string, _ []string, _ []bool) ([]string, error) {
+			note("keyboard-interactive")
+			return nil, errNoCred
+		}),
+		ssh.GSSAPIWithMICAuthMethod(gssapiProbe{note}, ""),
+	}
+}
+
+// gssapiProbe 只记录服务端提供了 gssapi-with-mic，不真正认证
+type gssapiProbe struct{ note func(string) }
+
+func (g gssapiProbe) InitSecContext(string, []byte, bool) ([]byte, bool, error) {
+	g.note("gssapi-with-mic")
+	return nil, false, errors.New("probe: no credentials")
+}
+func (gssapiProbe) GetMIC([]byte) ([]byte, error) { return nil, errors.New("probe: no credentials") }
+func (gssapiProbe) DeleteSecContext() error       { return nil }
+
+func tcpState(err error) TCPState {
+	var ne net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return TCPRefused
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return TCPUnreachable
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &ne) && ne.Timeout():
+		return TCPTimeout
+	}
+	return TCPError
+}
+
+// versionConn 记下服务端发来的第一段数据，用来取出版本行
+// （RFC 4253 允许版本行之前有其他文本行）
+type versionConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (c *versionConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	if len(c.buf) < 8192 {
+		c.buf = append(c.buf, p[:n]...)
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *versionConn) version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, line := range bytes.Split(c.buf, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			return strings.TrimRight(string(line), "\r")
+		}
+	}
+	return ""
+}
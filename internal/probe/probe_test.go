@@ -0,0 +1,189 @@
+package probe
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"sshmgr/internal/sshutil"
+)
+
+func TestStatus(t *testing.T) {
+	key := &sshutil.HostKey{Type: "ssh-ed25519", Fingerprint: "SHA256:x"}
+	tests := []struct {
+		name string
+		r    Result
+		want string
+	}{
+		{"refused", Result{TCP: TCPRefused}, "DOWN"},
+		{"timeout", Result{TCP: TCPTimeout}, "DOWN"},
+		{"unreachable", Result{TCP: TCPUnreachable}, "DOWN"},
+		{"dns error", Result{TCP: TCPError}, "ERR"},
+		{"not ssh", Result{TCP: TCPOpen}, "ERR"},
+		{"no host key", Result{TCP: TCPOpen, ServerVersion: "SSH-2.0-x", AuthMethods: []string{"password"}}, "ERR"},
+		{"authenticated", Result{TCP: TCPOpen, HostKey: key, Authenticated: true}, "OK"},
+		{"user rejected", Result{TCP: TCPOpen, HostKey: key, UserRejected: true}, "DENY"},
+		{"needs auth", Result{TCP: TCPOpen, HostKey: key, AuthMethods: []string{"publickey"}}, "AUTH"},
+		// NoAuth 只做到密钥交换，没有认证信息
+		{"kex only", Result{TCP: TCPOpen, HostKey: key}, "ERR"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Status(); got != tt.want {
+			t.Errorf("%s: Status() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestTCPState(t *testing.T) {
+	opErr := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+	}
+	tests := []struct {
+		err  error
+		want TCPState
+	}{
+		{opErr(syscall.ECONNREFUSED), TCPRefused},
+		{opErr(syscall.EHOSTUNREACH), TCPUnreachable},
+		{opErr(syscall.ENETUNREACH), TCPUnreachable},
+		{context.DeadlineExceeded, TCPTimeout},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: timeoutErr{}}, TCPTimeout},
+		{&net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}, TCPError},
+		{errors.New("other"), TCPError},
+	}
+	for _, tt := range tests {
+		if got := tcpState(tt.err); got != tt.want {
+			t.Errorf("tcpState(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestVersionConn(t *testing.T) {
+	tests := []struct{ data, want string }{
+		{"SSH-2.0-OpenSSH_9.6\r\n", "SSH-2.0-OpenSSH_9.6"},
+		{"Welcome\r\nSSH-2.0-dropbear\r\n\x00\x00", "SSH-2.0-dropbear"},
+		{"HTTP/1.1 400 Bad Request\r\n", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		c := &versionConn{buf: []byte(tt.data)}
+		if got := c.version(); got != tt.want {
+			t.Errorf("version(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+// listen 在 loopback 上起一个监听，每个连接交给 handle
+func listen(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				handle(c)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// sshServer 起一个最小的 SSH 服务端，cfg 决定它接受哪些认证
+func sshServer(t *testing.T, cfg *ssh.ServerConfig) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AddHostKey(signer)
+	return listen(t, func(c net.Conn) {
+		sc, chans, reqs, err := ssh.NewServerConn(c, cfg)
+		if err != nil {
+			return
+		}
+		defer sc.Close()
+		go ssh.DiscardRequests(reqs)
+		for ch := range chans {
+			_ = ch.Reject(ssh.Prohibited, "test")
+		}
+	})
+}
+
+func TestProbe(t *testing.T) {
+	closed := func() string {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := ln.Addr().String()
+		ln.Close()
+		return addr
+	}()
+	notSSH := listen(t, func(c net.Conn) { fmt.Fprint(c, "HTTP/1.1 400 Bad Request\r\n\r\n") })
+	noAuth := sshServer(t, &ssh.ServerConfig{NoClientAuth: true})
+	password := sshServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("denied")
+		},
+	})
+
+	tests := []struct {
+		name   string
+		addr   string
+		opts   Options
+		status string
+	}{
+		{"closed port", closed, Options{User: "u"}, "DOWN"},
+		{"not ssh", notSSH, Options{User: "u"}, "ERR"},
+		{"no auth needed", noAuth, Options{User: "u"}, "OK"},
+		{"password", password, Options{User: "u"}, "AUTH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Timeout = 3 * time.Second
+			r := Probe(context.Background(), tt.addr, tt.opts)
+			if got := r.Status(); got != tt.status {
+				t.Fatalf("Status() = %s, want %s (result %+v)", got, tt.status, r)
+			}
+			if tt.status == "OK" || tt.status == "AUTH" {
+				if r.ServerVersion == "" || r.HostKey == nil || r.HostKey.Type != ssh.KeyAlgoED25519 {
+					t.Errorf("version %q, host key %+v", r.ServerVersion, r.HostKey)
+				}
+			}
+			if tt.status == "AUTH" && (len(r.AuthMethods) != 1 || r.AuthMethods[0] != "password") {
+				t.Errorf("AuthMethods = %v, want [password]", r.AuthMethods)
+			}
+		})
+	}
+
+	r := Probe(context.Background(), password, Options{User: "u", Timeout: 3 * time.Second, NoAuth: true})
+	if r.Err != nil || r.HostKey == nil || len(r.AuthMethods) != 0 || r.Authenticated {
+		t.Errorf("NoAuth probe = %+v, want host key and nothing else", r)
+	}
+}
@@ -2,9 +2,13 @@ package reassoc
 
 import (
 	"encoding/json"
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
+
+	"sshmgr/internal/app"
 )
 
 type Entry struct {
@@ -18,9 +22,11 @@ type Table struct {
 	Map  map[string]Entry // fingerprint -> entry
 }
 
+// Load reads ~/.config/sshmgr/reassoc.json; a missing or unreadable file yields an empty table.
+// A table left in the old location (os.UserConfigDir, e.g. ~/Library/Application Support
+// on macOS) is moved over the first time.
 func Load() (*Table, error) {
-	cfg, _ := os.UserConfigDir()
-	path := filepath.Join(cfg, "sshmgr", "reassoc.json")
+	path := filepath.Join(app.ConfigDir(), "reassoc.json")
 
 	t := &Table{
 		Path: path,
@@ -28,6 +34,9 @@ func Load() (*Table, error) {
 	}
 
 	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = migrateLegacy(path)
+	}
 	if err == nil {
 		_ = json.Unmarshal(data, &t.Map)
 	}
@@ -35,6 +44,27 @@ func Load() (*Table, error) {
 	return t, nil
 }
 
+// migrateLegacy 把旧位置的表搬到 path；搬不动时（如跨设备）先读旧文件，
+// 下次 Save 会写到新位置
+func migrateLegacy(path string) ([]byte, error) {
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	legacy := filepath.Join(cfg, "sshmgr", "reassoc.json")
+	if legacy == path {
+		return nil, fs.ErrNotExist
+	}
+	data, err := os.ReadFile(legacy)
+	if err != nil {
+		return nil, err
+	}
+	if os.MkdirAll(filepath.Dir(path), 0o755) == nil {
+		_ = os.Rename(legacy, path)
+	}
+	return data, nil
+}
+
 func (t *Table) Save() error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -59,3 +89,29 @@ func (t *Table) Update(fp, name, ip string) {
 
 	t.Map[fp] = Entry{Name: name, IP: ip}
 }
+
+// Fingerprints returns every fingerprint recorded for name.
+func (t *Table) Fingerprints(name string) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []string
+	for fp, e := range t.Map {
+		if e.Name == name {
+			out = append(out, fp)
+		}
+	}
+	return out
+}
+
+// Forget drops all fingerprints recorded for name.
+func (t *Table) Forget(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for fp, e := range t.Map {
+		if e.Name == name {
+			delete(t.Map, fp)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package reassoc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMovesLegacyTable(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("AppData", filepath.Join(dir, "appdata"))
+
+	cfg, err := os.UserConfigDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	legacy := filepath.Join(cfg, "sshmgr", "reassoc.json")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte(`{"SHA256:abc":{"name":"mini","ip":"192.0.2.10"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tbl, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := tbl.Lookup("SHA256:abc"); !ok || e.Name != "mini" || e.IP != "192.0.2.10" {
+		t.Fatalf("Lookup = %+v %v, want the legacy entry", e, ok)
+	}
+	if tbl.Path == legacy {
+		t.Fatalf("Path = %s, still the legacy location", tbl.Path)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy table still there (err %v)", err)
+	}
+
+	// 再次加载从新位置读
+	tbl.Update("SHA256:def", "nas", "192.0.2.20")
+	if err := tbl.Save(); err != nil {
+		t.Fatal(err)
+	}
+	tbl, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tbl.Map) != 2 {
+		t.Errorf("Map = %v, want both entries", tbl.Map)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", filepath.Join(dir, "home"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("AppData", filepath.Join(dir, "appdata"))
+
+	tbl, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tbl.Map) != 0 {
+		t.Errorf("Map = %v, want empty", tbl.Map)
+	}
+	tbl.Update("SHA256:abc", "mini", "192.0.2.10")
+	tbl.Forget("mini")
+	if fps := tbl.Fingerprints("mini"); len(fps) != 0 {
+		t.Errorf("Fingerprints after Forget = %v", fps)
+	}
+}
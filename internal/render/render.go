@@ -0,0 +1,262 @@
+// Package render writes command results as a table or as machine-readable
+// json/yaml/csv/tsv with stable, snake_case field names.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	CSV   Format = "csv"
+	TSV   Format = "tsv"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return Table, nil
+	case Table, JSON, YAML, CSV, TSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid --output: %s (want table|json|yaml|csv|tsv)", s)
+	}
+}
+
+// Column describes one field.
+//
+// Key is the machine field name (json/yaml key, csv header) and must stay
+// stable. Header is the table heading; an empty Header hides the column in
+// table mode. Format, if set, renders the value for table mode only.
+type Column struct {
+	Key    string
+	Header string
+	Format func(v any) string
+}
+
+// Rows is a list of records sharing the same columns. Values may be
+// string, bool, integers, float64, time.Time or nil.
+type Rows struct {
+	Columns []Column
+	data    [][]any
+}
+
+func New(cols ...Column) *Rows { return &Rows{Columns: cols} }
+
+func (r *Rows) Add(values ...any) {
+	if len(values) != len(r.Columns) {
+		panic(fmt.Sprintf("render: %d values for %d columns", len(values), len(r.Columns)))
+	}
+	r.data = append(r.data, values)
+}
+
+func (r *Rows) Len() int { return len(r.data) }
+
+// Write renders all rows as a list.
+func (r *Rows) Write(w io.Writer, f Format) error {
+	switch f {
+	case JSON:
+		out := make([]orderedMap, 0, len(r.data))
+		for _, row := range r.data {
+			out = append(out, r.object(row))
+		}
+		return writeJSON(w, out)
+	case YAML:
+		if len(r.data) == 0 {
+			_, err := fmt.Fprintln(w, "[]")
+			return err
+		}
+		for _, row := range r.data {
+			if err := r.writeYAML(w, row, "- "); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CSV, TSV:
+		return r.writeDelimited(w, f)
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		var heads []string
+		for _, c := range r.Columns {
+			if c.Header != "" {
+				heads = append(heads, c.Header)
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(heads, "\t"))
+		for _, row := range r.data {
+			var cells []string
+			for i, c := range r.Columns {
+				if c.Header != "" {
+					cells = append(cells, c.display(row[i]))
+				}
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// WriteOne renders the first row as a single record (json object, yaml
+// mapping, "key: value" lines in table mode).
+func (r *Rows) WriteOne(w io.Writer, f Format) error {
+	if len(r.data) == 0 {
+		return nil
+	}
+	row := r.data[0]
+	switch f {
+	case JSON:
+		return writeJSON(w, r.object(row))
+	case YAML:
+		return r.writeYAML(w, row, "")
+	case CSV, TSV:
+		return r.writeDelimited(w, f)
+	default:
+		for i, c := range r.Columns {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", c.Key, c.display(row[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (c Column) display(v any) string {
+	if c.Format != nil {
+		return c.Format(v)
+	}
+	return plain(v)
+}
+
+// plain 是 csv/tsv 和默认表格的文本形式；时间用 RFC3339 UTC
+func plain(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.UTC().Format(time.RFC3339)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// machine 把值转成 json/yaml 用的形式：零时间为 null
+func machine(v any) any {
+	if t, ok := v.(time.Time); ok {
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+func (r *Rows) writeDelimited(w io.Writer, f Format) error {
+	cw := csv.NewWriter(w)
+	if f == TSV {
+		cw.Comma = '\t'
+	}
+	head := make([]string, len(r.Columns))
+	for i, c := range r.Columns {
+		head[i] = c.Key
+	}
+	if err := cw.Write(head); err != nil {
+		return err
+	}
+	for _, row := range r.data {
+		rec := make([]string, len(row))
+		for i, v := range row {
+			rec[i] = plain(v)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *Rows) writeYAML(w io.Writer, row []any, first string) error {
+	indent := strings.Repeat(" ", len(first))
+	for i, c := range r.Columns {
+		prefix := indent
+		if i == 0 {
+			prefix = first
+		}
+		if _, err := fmt.Fprintf(w, "%s%s: %s\n", prefix, c.Key, yamlScalar(machine(row[i]))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlScalar: 字符串一律用 JSON 引号（JSON 字符串也是合法 YAML），避免 yes/no/数字被误判类型
+func yamlScalar(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		b, _ := json.Marshal(x)
+		return string(b)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// orderedMap 让 JSON 字段按列顺序输出
+type orderedMap struct {
+	keys   []string
+	values []any
+}
+
+func (r *Rows) object(row []any) orderedMap {
+	m := orderedMap{}
+	for i, c := range r.Columns {
+		m.keys = append(m.keys, c.Key)
+		m.values = append(m.values, machine(row[i]))
+	}
+	return m
+}
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(m.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(kb)
+		b.WriteByte(':')
+		b.Write(vb)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
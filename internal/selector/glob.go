@@ -0,0 +1,121 @@
+package selector
+
+import (
+	"errors"
+	"unicode/utf8"
+)
+
+// SQLFunc is the SQL function Selector.SQL calls; internal/db registers it
+// with Glob so the database and Match use the same matcher.
+const SQLFunc = "sshmgr_glob"
+
+var errBadPattern = errors.New("unterminated [ in pattern")
+
+// Glob reports whether s matches the pattern p: * is any sequence, ? one
+// character, [abc] / [a-z] a class and [^...] or [!...] its complement.
+// There is no escape character; a ] right after [ (or [^) is literal.
+// Comparison is exact, callers lower-case both sides.
+// Malformed patterns (see ValidGlob) match nothing.
+func Glob(p, s string) bool {
+	// 只回溯到上一个 *，不会指数爆炸
+	star, next := -1, 0
+	pi, si := 0, 0
+	for si < len(s) || pi < len(p) {
+		if pi < len(p) {
+			switch p[pi] {
+			case '*':
+				star, next = pi, si
+				pi++
+				continue
+			case '?':
+				if si < len(s) {
+					_, n := utf8.DecodeRuneInString(s[si:])
+					pi, si = pi+1, si+n
+					continue
+				}
+			case '[':
+				end, ok := classEnd(p, pi)
+				if !ok {
+					return false
+				}
+				if si < len(s) {
+					r, n := utf8.DecodeRuneInString(s[si:])
+					if inClass(p[pi+1:end], r) {
+						pi, si = end+1, si+n
+						continue
+					}
+				}
+			default:
+				if si < len(s) {
+					r, n := utf8.DecodeRuneInString(s[si:])
+					pr, pn := utf8.DecodeRuneInString(p[pi:])
+					if r == pr {
+						pi, si = pi+pn, si+n
+						continue
+					}
+				}
+			}
+		}
+		if star < 0 || next >= len(s) {
+			return false
+		}
+		_, n := utf8.DecodeRuneInString(s[next:])
+		next += n
+		pi, si = star+1, next
+	}
+	return true
+}
+
+// ValidGlob returns an error for malformed patterns (an unterminated [).
+func ValidGlob(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '[' {
+			end, ok := classEnd(pattern, i)
+			if !ok {
+				return errBadPattern
+			}
+			i = end
+		}
+	}
+	return nil
+}
+
+// classEnd 返回 p[i]=='[' 对应的 ']' 的位置
+func classEnd(p string, i int) (int, bool) {
+	j := i + 1
+	if j < len(p) && (p[j] == '^' || p[j] == '!') {
+		j++
+	}
+	if j < len(p) && p[j] == ']' {
+		j++
+	}
+	for ; j < len(p); j++ {
+		if p[j] == ']' {
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+// inClass 判断 r 是否在 class（[ ] 之间的内容）里
+func inClass(class string, r rune) bool {
+	negate := false
+	if class != "" && (class[0] == '^' || class[0] == '!') {
+		negate, class = true, class[1:]
+	}
+	match := false
+	for class != "" {
+		lo, n := utf8.DecodeRuneInString(class)
+		class = class[n:]
+		hi := lo
+		// a-z；结尾的 - 是普通字符
+		if len(class) >= 2 && class[0] == '-' {
+			hi, n = utf8.DecodeRuneInString(class[1:])
+			class = class[1+n:]
+		}
+		if lo <= r && r <= hi {
+			match = true
+		}
+	}
+	return match != negate
+}
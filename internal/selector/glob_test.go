@@ -0,0 +1,53 @@
+package selector
+
+import "testing"
+
+var globTests = []struct {
+	pattern, s string
+	want       bool
+}{
+	{"lab", "lab", true},
+	{"lab", "lab1", false},
+	{"lab*", "lab", true},
+	{"lab*", "lab-07", true},
+	{"*-07", "lab-07", true},
+	{"*", "", true},
+	{"", "", true},
+	{"", "x", false},
+	{"l?b", "lab", true},
+	{"l?b", "lb", false},
+	{"a*b*c", "axxbyyc", true},
+	{"a*b*c", "axxbyy", false},
+	{"*a*a*a*a*b", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", false},
+	{"lab-[0-9]", "lab-7", true},
+	{"lab-[0-9]", "lab-x", false},
+	{"lab-[^0-9]", "lab-x", true},
+	{"lab-[!0-9]", "lab-7", false},
+	{"[]a]", "]", true},
+	{"[a-]", "-", true},
+	{"web/*", "web/a/b", true}, // 与 path.Match 不同，* 也匹配 /
+	{"é?", "éa", true},
+	{"?", "é", true},
+	{"[lab", "[lab", false}, // 不合法的模式什么都不匹配
+}
+
+func TestGlob(t *testing.T) {
+	for _, tt := range globTests {
+		if got := Glob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Glob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestValidGlob(t *testing.T) {
+	for _, p := range []string{"", "a*", "[a-z]", "[]]", "[^]x]"} {
+		if err := ValidGlob(p); err != nil {
+			t.Errorf("ValidGlob(%q) = %v", p, err)
+		}
+	}
+	for _, p := range []string{"[", "a[b", "[]", "x[^"} {
+		if err := ValidGlob(p); err == nil {
+			t.Errorf("ValidGlob(%q) = nil, want error", p)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Package selector parses host selector expressions such as
+// "tag=lab,!tag=retired,name=mac-*".
+//
+// Terms are comma-separated and all of them must match. Each term is
+// key=value, key!=value or !key=value, where key is tag, name, host or user
+// and value is a glob (* ? [..], see Glob) compared case-insensitively. A
+// bare word is short for tag=word.
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Term is one key=value condition.
+type Term struct {
+	Key   string
+	Value string
+	Not   bool
+}
+
+// Selector is a list of terms that must all match. The zero value matches
+// every host.
+type Selector []Term
+
+// Host is what a selector is evaluated against.
+type Host struct {
+	Name string
+	User string
+	Host string
+	Tags []string
+}
+
+var keys = map[string]bool{"tag": true, "name": true, "host": true, "user": true}
+
+func Parse(expr string) (Selector, error) {
+	var out Selector
+	for _, raw := range strings.Split(expr, ",") {
+		s := strings.TrimSpace(raw)
+		if s == "" {
+			continue
+		}
+
+		var t Term
+		if strings.HasPrefix(s, "!") {
+			t.Not = true
+			s = strings.TrimSpace(s[1:])
+		}
+		k, v, ok := strings.Cut(s, "=")
+		switch {
+		case !ok:
+			k, v = "tag", s
+		case strings.HasSuffix(k, "!"):
+			k = strings.TrimSuffix(k, "!")
+			t.Not = !t.Not
+		}
+		t.Key = strings.ToLower(strings.TrimSpace(k))
+		t.Value = strings.ToLower(strings.TrimSpace(v))
+
+		if !keys[t.Key] {
+			return nil, fmt.Errorf("invalid selector %q: unknown key %q (want tag|name|host|user)", raw, t.Key)
+		}
+		if t.Value == "" {
+			return nil, fmt.Errorf("invalid selector %q: empty value", raw)
+		}
+		if err := ValidGlob(t.Value); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %v", raw, err)
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// Match reports whether h satisfies every term.
+func (s Selector) Match(h Host) bool {
+	for _, t := range s {
+		if t.match(h) == t.Not {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Term) match(h Host) bool {
+	switch t.Key {
+	case "tag":
+		for _, tag := range h.Tags {
+			if glob(t.Value, tag) {
+				return true
+			}
+		}
+		return false
+	case "name":
+		return glob(t.Value, h.Name)
+	case "host":
+		return glob(t.Value, h.Host)
+	case "user":
+		return glob(t.Value, h.User)
+	}
+	return false
+}
+
+func glob(pattern, s string) bool {
+	return Glob(pattern, strings.ToLower(s))
+}
+
+// SQL returns a WHERE condition (and its args) equivalent to Match for the
+// hosts table aliased as alias; tags are looked up in host_tags/tags.
+// Patterns go through SQLFunc, i.e. Glob, not SQLite's GLOB.
+// An empty selector yields "1".
+func (s Selector) SQL(alias string) (string, []any) {
+	if len(s) == 0 {
+		return "1", nil
+	}
+	var conds []string
+	var args []any
+	for _, t := range s {
+		var c string
+		switch t.Key {
+		case "tag":
+			c = `EXISTS (SELECT 1 FROM host_tags ht JOIN tags t ON t.id=ht.tag_id WHERE ht.host_id=` + alias + `.id AND ` + SQLFunc + `(?, lower(t.name)))`
+		default:
+			c = SQLFunc + `(?, lower(` + alias + `.` + t.Key + `))`
+		}
+		if t.Not {
+			c = "NOT " + c
+		}
+		conds = append(conds, c)
+		args = append(args, t.Value)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// String renders s back into expression form.
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, t := range s {
+		op := "="
+		if t.Not {
+			op = "!="
+		}
+		parts[i] = t.Key + op + t.Value
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,116 @@
+package selector
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Selector
+	}{
+		{"", nil},
+		{" , ", nil},
+		{"lab", Selector{{Key: "tag", Value: "lab"}}},
+		{"!lab", Selector{{Key: "tag", Value: "lab", Not: true}}},
+		{"Tag=Lab", Selector{{Key: "tag", Value: "lab"}}},
+		{"name=mac-*, user!=root", Selector{{Key: "name", Value: "mac-*"}, {Key: "user", Value: "root", Not: true}}},
+		{"!host=10.0.*", Selector{{Key: "host", Value: "10.0.*", Not: true}}},
+		// 两次否定抵消
+		{"!tag!=lab", Selector{{Key: "tag", Value: "lab"}}},
+		{" name = web[0-9] ", Selector{{Key: "name", Value: "web[0-9]"}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"port=22", "name=", "tag!=", "name=web[0-9", "!"} {
+		if s, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) = %v, want error", expr, s)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	h := Host{Name: "Mac-Mini", User: "alice", Host: "192.0.2.10", Tags: []string{"lab", "Home"}}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"lab", true},
+		{"home", true},
+		{"!lab", false},
+		{"tag=l*", true},
+		{"tag=retired", false},
+		{"!tag=retired", true},
+		{"name=mac-*", true},
+		{"name=mac-?", false},
+		{"host=192.0.2.*", true},
+		{"user!=root", true},
+		{"user=root", false},
+		{"lab,name=mac-*,user=alice", true},
+		{"lab,user=root", false},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Match(h); got != tt.want {
+			t.Errorf("%q.Match(%+v) = %v, want %v", tt.expr, h, got, tt.want)
+		}
+	}
+	if s, _ := Parse("tag=*"); s.Match(Host{Name: "untagged"}) {
+		t.Error("tag=* matched a host without tags")
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct{ expr, want string }{
+		{"lab", "tag=lab"},
+		{"!lab, Name=Web*", "tag!=lab,name=web*"},
+		{"user!=root", "user!=root"},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestSQL(t *testing.T) {
+	if cond, args := Selector(nil).SQL("h"); cond != "1" || args != nil {
+		t.Errorf("empty selector: %q %v", cond, args)
+	}
+
+	s, err := Parse("lab,!name=web*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, args := s.SQL("h")
+	if !reflect.DeepEqual(args, []any{"lab", "web*"}) {
+		t.Errorf("args = %v", args)
+	}
+	parts := strings.Split(cond, " AND NOT ")
+	if len(parts) != 2 ||
+		!strings.HasPrefix(parts[0], "EXISTS (") || !strings.Contains(parts[0], "ht.host_id=h.id") ||
+		parts[1] != SQLFunc+"(?, lower(h.name))" {
+		t.Errorf("SQL = %s", cond)
+	}
+}
@@ -0,0 +1,339 @@
+// Package sshclient is a small native SSH client on golang.org/x/crypto/ssh.
+//
+// It is used by --engine native instead of the ssh binary, so connection
+// failures come back as typed errors (*Error with a Kind) rather than
+// OpenSSH messages that have to be matched as text.
+package sshclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Target is one host to connect to, either a jump hop or the final host.
+type Target struct {
+	User string
+	Addr string // host:port
+
+	// HostKey checks the key the server presented; it must be set.
+	HostKey ssh.HostKeyCallback
+	// HostKeyAlgorithms limits what the server may present, e.g. to the key
+	// types already pinned. Empty means the library default.
+	HostKeyAlgorithms []string
+
+	// Password returns the password for password and keyboard-interactive
+	// auth. It is called at most once; nil makes both methods fail (they
+	// still show up in Error.Offered).
+	Password func() (string, error)
+
+	// IdentityFiles, if set, replaces Config.IdentityFiles for this target
+	// and turns the agent off, e.g. to check that one specific key works.
+	IdentityFiles []string
+}
+
+// Config applies to every hop.
+type Config struct {
+	// IdentityFiles are private keys to offer. Encrypted keys are skipped;
+	// load them into ssh-agent instead.
+	IdentityFiles []string
+	// UseAgent offers the keys of $SSH_AUTH_SOCK.
+	UseAgent bool
+	Timeout  time.Duration
+}
+
+// Kind classifies a connection failure.
+type Kind string
+
+const (
+	KindDial      Kind = "dial"      // TCP connect failed
+	KindHandshake Kind = "handshake" // not SSH, or no common algorithms
+	KindHostKey   Kind = "hostkey"   // HostKey callback rejected the key
+	KindAuth      Kind = "auth"      // every offered auth method failed
+)
+
+// Error is returned by Dial.
+type Error struct {
+	Kind    Kind
+	Addr    string
+	Offered []string // auth methods the server accepted to try (KindAuth)
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Kind == KindAuth {
+		return fmt.Sprintf("%s: authentication failed (server offers %s): %v", e.Addr, strings.Join(e.Offered, ","), e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Addr, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// IsKind reports whether err is an *Error of kind k.
+func IsKind(err error, k Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == k
+}
+
+// Client is a connection to the final target, possibly through jump hops.
+type Client struct {
+	*ssh.Client
+	hops []*ssh.Client
+}
+
+// Dial connects to the last target through the ones before it.
+func Dial(cfg Config, targets ...Target) (*Client, error) {
+	if len(targets) == 0 {
+		return nil, errors.New("sshclient: no target")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	c := &Client{}
+	var prev *ssh.Client
+	for i, t := range targets {
+		var conn net.Conn
+		var err error
+		if prev == nil {
+			conn, err = net.DialTimeout("tcp", t.Addr, cfg.Timeout)
+		} else {
+			conn, err = prev.Dial("tcp", t.Addr)
+		}
+		if err != nil {
+			c.Close()
+			return nil, &Error{Kind: KindDial, Addr: t.Addr, Err: err}
+		}
+
+		client, err := handshake(conn, cfg, t)
+		if err != nil {
+			conn.Close()
+			c.Close()
+			return nil, err
+		}
+		if i < len(targets)-1 {
+			c.hops = append(c.hops, client)
+		} else {
+			c.Client = client
+		}
+		prev = client
+	}
+	return c, nil
+}
+
+// Close closes the target and every hop.
+func (c *Client) Close() error {
+	var err error
+	if c.Client != nil {
+		err = c.Client.Close()
+	}
+	for i := len(c.hops) - 1; i >= 0; i-- {
+		_ = c.hops[i].Close()
+	}
+	return err
+}
+
+func handshake(conn net.Conn, cfg Config, t Target) (*ssh.Client, error) {
+	var mu sync.Mutex
+	keyOK, keyRejected := false, false
+	var offered []string
+	note := func(m string) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, o := range offered {
+			if o == m {
+				return
+			}
+		}
+		offered = append(offered, m)
+	}
+
+	if len(t.IdentityFiles) > 0 {
+		cfg.IdentityFiles, cfg.UseAgent = t.IdentityFiles, false
+	}
+	auth, closeAgent := authMethods(cfg, t.Password, note)
+	defer closeAgent()
+
+	cc := &ssh.ClientConfig{
+		User: t.User,
+		Auth: auth,
+		HostKeyCallback: func(host string, remote net.Addr, key ssh.PublicKey) error {
+			if err := t.HostKey(host, remote, key); err != nil {
+				keyRejected = true
+				return err
+			}
+			keyOK = true
+			return nil
+		},
+		HostKeyAlgorithms: t.HostKeyAlgorithms,
+		Timeout:           cfg.Timeout,
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(cfg.Timeout))
+	sc, chans, reqs, err := ssh.NewClientConn(conn, t.Addr, cc)
+	if err != nil {
+		// host key 回调之前失败是握手问题，之后失败就是认证
+		kind := KindHandshake
+		switch {
+		case keyOK:
+			kind = KindAuth
+		case keyRejected:
+			kind = KindHostKey
+		}
+		return nil, &Error{Kind: kind, Addr: t.Addr, Offered: offered, Err: err}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sc, chans, reqs), nil
+}
+
+// authMethods 的回调只有在服务端提供该方式时才会被调用，借此记下 offered。
+func authMethods(cfg Config, getPassword func() (string, error), note func(string)) ([]ssh.AuthMethod, func()) {
+	signers, closeAgent := Signers(cfg)
+
+	methods := []ssh.AuthMethod{
+		ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			note("publickey")
+			return signers, nil
+		}),
+	}
+	if getPassword != nil {
+		var once sync.Once
+		var pw string
+		var pwErr error
+		password := func() (string, error) {
+			once.Do(func() { pw, pwErr = getPassword() })
+			return pw, pwErr
+		}
+		methods = append(methods,
+			ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				note("keyboard-interactive")
+				answers := make([]string, len(questions))
+				for i := range questions {
+					// 不回显的问题当作密码，其余（如 banner 式提示）留空
+					if !echos[i] {
+						p, err := password()
+						if err != nil {
+							return nil, err
+						}
+						answers[i] = p
+					}
+				}
+				return answers, nil
+			}),
+			ssh.PasswordCallback(func() (string, error) {
+				note("password")
+				return password()
+			}),
+		)
+	} else {
+		// 仍然记录服务端是否接受密码登录
+		methods = append(methods,
+			ssh.KeyboardInteractive(func(string, string, []string, []bool) ([]string, error) {
+				note("keyboard-interactive")
+				return nil, errors.New("no password available")
+			}),
+			ssh.PasswordCallback(func() (string, error) {
+				note("password")
+				return "", errors.New("no password available")
+			}),
+		)
+	}
+	return methods, closeAgent
+}
+
+// Signers returns the keys of the agent (if cfg.UseAgent) followed by the
+// readable, unencrypted cfg.IdentityFiles. The agent signers stay usable
+// until closeAgent is called.
+func Signers(cfg Config) (signers []ssh.Signer, closeAgent func()) {
+	closeAgent = func() {}
+	if cfg.UseAgent {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.Dial("unix", sock); err == nil {
+				closeAgent = func() { conn.Close() }
+				if s, err := agent.NewClient(conn).Signers(); err == nil {
+					signers = append(signers, s...)
+				}
+			}
+		}
+	}
+	for _, f := range cfg.IdentityFiles {
+		b, err := os.ReadFile(expandHome(f))
+		if err != nil {
+			continue
+		}
+		if s, err := ssh.ParsePrivateKey(b); err == nil {
+			signers = append(signers, s)
+		}
+	}
+	return signers, closeAgent
+}
+
+// DefaultIdentityFiles are the keys OpenSSH tries when none is configured.
+func DefaultIdentityFiles() []string {
+	return []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+}
+
+// Run executes cmd and returns its exit status; -1 means the session ended
+// without one (e.g. the connection dropped).
+func (c *Client) Run(cmd string, env map[string]string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	s, err := c.NewSession()
+	if err != nil {
+		return -1, err
+	}
+	defer s.Close()
+	for k, v := range env {
+		_ = s.Setenv(k, v) // sshd 的 AcceptEnv 不允许时忽略
+	}
+	s.Stdin, s.Stdout, s.Stderr = stdin, stdout, stderr
+	return exitStatus(s.Run(cmd))
+}
+
+// ForwardAgent makes $SSH_AUTH_SOCK available on the remote side of s.
+func (c *Client) ForwardAgent(s *ssh.Session) error {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return errors.New("SSH_AUTH_SOCK is not set")
+	}
+	if err := agent.ForwardToRemote(c.Client, sock); err != nil {
+		return err
+	}
+	return agent.RequestAgentForwarding(s)
+}
+
+func exitStatus(err error) (int, error) {
+	var ee *ssh.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case errors.As(err, &ee):
+		return ee.ExitStatus(), nil
+	}
+	return -1, err
+}
+
+// KeyAlgorithms returns the host key algorithms that present a key of
+// type keyType (as reported by ssh-keyscan / PublicKey.Type()).
+func KeyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
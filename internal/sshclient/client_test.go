@@ -0,0 +1,483 @@
+package sshclient
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testServer 是 loopback 上的最小 SSH 服务端：exec 请求按命令应答，
+// direct-tcpip 转发到请求的地址（当跳板机用）。
+type testServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+
+	forwarded atomic.Int32 // 转发过的 direct-tcpip 通道数
+
+	mu  sync.Mutex
+	env map[string]string
+}
+
+func newTestServer(t *testing.T, cfg *ssh.ServerConfig) *testServer {
+	t.Helper()
+	signer := newSigner(t)
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &testServer{addr: ln.Addr().String(), hostKey: signer.PublicKey(), env: map[string]string{}}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, cfg)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(c net.Conn, cfg *ssh.ServerConfig) {
+	defer c.Close()
+	sc, chans, reqs, err := ssh.NewServerConn(c, cfg)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		switch nc.ChannelType() {
+		case "session":
+			go s.session(nc)
+		case "direct-tcpip":
+			go s.forward(nc)
+		default:
+			_ = nc.Reject(ssh.UnknownChannelType, nc.ChannelType())
+		}
+	}
+}
+
+// session 支持 env 和 exec：
+// "echo <s>" 输出 s，"exit <n>" 以 n 退出，"noexit" 不带 exit-status 直接关闭
+func (s *testServer) session(nc ssh.NewChannel) {
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	for req := range reqs {
+		switch req.Type {
+		case "env":
+			var kv struct{ Key, Value string }
+			if ssh.Unmarshal(req.Payload, &kv) == nil {
+				s.mu.Lock()
+				s.env[kv.Key] = kv.Value
+				s.mu.Unlock()
+			}
+			_ = req.Reply(true, nil)
+		case "exec":
+			var cmd struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &cmd); err != nil {
+				_ = req.Reply(false, nil)
+				return
+			}
+			_ = req.Reply(true, nil)
+			verb, arg, _ := strings.Cut(cmd.Command, " ")
+			status := 0
+			switch verb {
+			case "echo":
+				fmt.Fprintln(ch, arg)
+			case "exit":
+				status, _ = strconv.Atoi(arg)
+			case "noexit":
+				return
+			}
+			_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *testServer) forward(nc ssh.NewChannel) {
+	var p struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(nc.ExtraData(), &p); err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(p.Host, strconv.Itoa(int(p.Port))))
+	if err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.forwarded.Add(1)
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		ch.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, ch)
+	conn.Close()
+	ch.Close()
+}
+
+func (s *testServer) target(user string) Target {
+	return Target{User: user, Addr: s.addr, HostKey: ssh.FixedHostKey(s.hostKey)}
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newKeyFile 生成一个 ed25519 私钥文件，返回路径、公钥和私钥
+func newKeyFile(t *testing.T) (string, ssh.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, pub, priv
+}
+
+func passwordConfig(want string) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+			if string(pw) == want {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+}
+
+func keyboardConfig(want string) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(_ ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			ans, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if len(ans) == 1 && ans[0] == want {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+}
+
+func publicKeyConfig(allowed ssh.PublicKey) *ssh.ServerConfig {
+	return &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(allowed.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+}
+
+func password(pw string) func() (string, error) {
+	return func() (string, error) { return pw, nil }
+}
+
+func TestDialAuth(t *testing.T) {
+	keyFile, pub, _ := newKeyFile(t)
+	otherKey, _, _ := newKeyFile(t)
+
+	both := passwordConfig("secret")
+	both.KeyboardInteractiveCallback = keyboardConfig("secret").KeyboardInteractiveCallback
+
+	tests := []struct {
+		name     string
+		server   *ssh.ServerConfig
+		cfg      Config
+		password func() (string, error)
+		offered  []string // nil 表示应当登录成功
+	}{
+		{name: "password", server: passwordConfig("secret"), password: password("secret")},
+		{name: "keyboard-interactive", server: keyboardConfig("secret"), password: password("secret")},
+		{name: "publickey", server: publicKeyConfig(pub), cfg: Config{IdentityFiles: []string{keyFile}}},
+		{name: "wrong password", server: passwordConfig("secret"), password: password("nope"), offered: []string{"password"}},
+		{name: "no password", server: both, offered: []string{"keyboard-interactive", "password"}},
+		{name: "wrong key", server: publicKeyConfig(pub), cfg: Config{IdentityFiles: []string{otherKey}}, offered: []string{"publickey"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.server)
+			target := srv.target("alice")
+			target.Password = tt.password
+			tt.cfg.Timeout = 5 * time.Second
+
+			c, err := Dial(tt.cfg, target)
+			if tt.offered == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer c.Close()
+				out := &strings.Builder{}
+				if code, err := c.Run("echo hi", nil, nil, out, io.Discard); err != nil || code != 0 || out.String() != "hi\n" {
+					t.Errorf("Run = %d, %v, %q", code, err, out)
+				}
+				return
+			}
+
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != KindAuth {
+				t.Fatalf("err = %v, want a KindAuth *Error", err)
+			}
+			if strings.Join(e.Offered, ",") != strings.Join(tt.offered, ",") {
+				t.Errorf("Offered = %v, want %v", e.Offered, tt.offered)
+			}
+		})
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	srv := newTestServer(t, passwordConfig("secret"))
+	cfg := Config{Timeout: 5 * time.Second}
+
+	// host key 不对：在认证之前就失败，不会把密码交出去
+	asked := false
+	target := srv.target("alice")
+	target.HostKey = ssh.FixedHostKey(newSigner(t).PublicKey())
+	target.Password = func() (string, error) { asked = true; return "secret", nil }
+	_, err := Dial(cfg, target)
+	if !IsKind(err, KindHostKey) {
+		t.Errorf("wrong host key: err = %v, want KindHostKey", err)
+	}
+	if asked {
+		t.Error("password was requested although the host key was rejected")
+	}
+
+	// 只接受服务端没有的 key 类型
+	target = srv.target("alice")
+	target.HostKeyAlgorithms = KeyAlgorithms(ssh.KeyAlgoRSA)
+	if _, err := Dial(cfg, target); !IsKind(err, KindHandshake) {
+		t.Errorf("no common host key algorithm: err = %v, want KindHandshake", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+	if _, err := Dial(cfg, Target{User: "alice", Addr: closed, HostKey: ssh.InsecureIgnoreHostKey()}); !IsKind(err, KindDial) {
+		t.Errorf("closed port: err = %v, want KindDial", err)
+	}
+
+	// 不是 SSH
+	notSSH, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer notSSH.Close()
+	go func() {
+		for {
+			c, err := notSSH.Accept()
+			if err != nil {
+				return
+			}
+			fmt.Fprint(c, "HTTP/1.1 400 Bad Request\r\n\r\n")
+			c.Close()
+		}
+	}()
+	if _, err := Dial(cfg, Target{User: "alice", Addr: notSSH.Addr().String(), HostKey: ssh.InsecureIgnoreHostKey()}); !IsKind(err, KindHandshake) {
+		t.Errorf("not ssh: err = %v, want KindHandshake", err)
+	}
+}
+
+func TestDialJump(t *testing.T) {
+	keyFile, pub, _ := newKeyFile(t)
+	jump := newTestServer(t, passwordConfig("hop"))
+	dst := newTestServer(t, publicKeyConfig(pub))
+
+	hop := jump.target("ops")
+	hop.Password = password("hop")
+	c, err := Dial(Config{IdentityFiles: []string{keyFile}, Timeout: 5 * time.Second}, hop, dst.target("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	out := &strings.Builder{}
+	code, err := c.Run("echo via jump", map[string]string{"LANG": "C"}, nil, out, io.Discard)
+	if err != nil || code != 0 || out.String() != "via jump\n" {
+		t.Errorf("Run = %d, %v, %q", code, err, out)
+	}
+	if n := jump.forwarded.Load(); n != 1 {
+		t.Errorf("jump host forwarded %d connections, want 1", n)
+	}
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+	if dst.env["LANG"] != "C" {
+		t.Errorf("env = %v", dst.env)
+	}
+
+	// 跳板机认证失败时报的是跳板机的地址
+	hop.Password = password("wrong")
+	_, err = Dial(Config{IdentityFiles: []string{keyFile}, Timeout: 5 * time.Second}, hop, dst.target("alice"))
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != KindAuth || e.Addr != jump.addr {
+		t.Errorf("err = %v, want KindAuth for %s", err, jump.addr)
+	}
+}
+
+// Target.IdentityFiles 只用指定的 key，agent 里的 key 不能参与（key rotate 靠这个确认新 key 可用）
+func TestTargetIdentityFilesDisableAgent(t *testing.T) {
+	_, agentPub, agentPriv := newKeyFile(t)
+	otherFile, _, _ := newKeyFile(t)
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: agentPriv}); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_ = agent.ServeAgent(keyring, c)
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	srv := newTestServer(t, publicKeyConfig(agentPub))
+	cfg := Config{UseAgent: true, Timeout: 5 * time.Second}
+
+	c, err := Dial(cfg, srv.target("alice"))
+	if err != nil {
+		t.Fatalf("agent key: %v", err)
+	}
+	c.Close()
+
+	target := srv.target("alice")
+	target.IdentityFiles = []string{otherFile}
+	if _, err := Dial(cfg, target); !IsKind(err, KindAuth) {
+		t.Errorf("IdentityFiles with another key: err = %v, want KindAuth (agent must be off)", err)
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	srv := newTestServer(t, passwordConfig("secret"))
+	target := srv.target("alice")
+	target.Password = password("secret")
+	c, err := Dial(Config{Timeout: 5 * time.Second}, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		cmd     string
+		code    int
+		wantErr bool
+	}{
+		{"exit 0", 0, false},
+		{"exit 3", 3, false},
+		{"noexit", -1, true},
+	}
+	for _, tt := range tests {
+		code, err := c.Run(tt.cmd, nil, nil, io.Discard, io.Discard)
+		if code != tt.code || (err != nil) != tt.wantErr {
+			t.Errorf("Run(%q) = %d, %v; want %d, error %v", tt.cmd, code, err, tt.code, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	if got := KeyAlgorithms(ssh.KeyAlgoED25519); len(got) != 1 || got[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("ed25519: %v", got)
+	}
+	if got := KeyAlgorithms(ssh.KeyAlgoRSA); len(got) != 3 || got[0] != ssh.KeyAlgoRSASHA512 {
+		t.Errorf("rsa: %v", got)
+	}
+}
+
+// go test 的 stdin 不是终端，Shell 不申请 PTY，只验证退出码的处理
+func TestShellCommandExitStatus(t *testing.T) {
+	srv := newTestServer(t, passwordConfig("secret"))
+	target := srv.target("alice")
+	target.Password = password("secret")
+	c, err := Dial(Config{Timeout: 5 * time.Second}, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		cmd  string
+		code int
+	}{
+		{"exit 3", 3},
+		{"noexit", 0}, // 没带 exit-status 按 0 处理
+	}
+	for _, tt := range tests {
+		code, err := c.Shell(ShellOptions{Command: tt.cmd, Env: map[string]string{"TERM": "dumb"}})
+		if err != nil || code != tt.code {
+			t.Errorf("Shell(%q) = %d, %v; want %d", tt.cmd, code, err, tt.code)
+		}
+	}
+}
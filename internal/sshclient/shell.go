@@ -0,0 +1,80 @@
+package sshclient
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// ShellOptions controls an interactive session.
+type ShellOptions struct {
+	Env          map[string]string
+	ForwardAgent bool
+	// Command runs instead of the login shell (like RemoteCommand).
+	Command string
+}
+
+// Shell starts an interactive session on the local terminal. When stdin is
+// a terminal it is switched to raw mode and a PTY of the same size (kept in
+// sync on resize) is requested.
+func (c *Client) Shell(opts ShellOptions) (int, error) {
+	s, err := c.NewSession()
+	if err != nil {
+		return -1, err
+	}
+	defer s.Close()
+
+	for k, v := range opts.Env {
+		_ = s.Setenv(k, v)
+	}
+	if opts.ForwardAgent {
+		if err := c.ForwardAgent(s); err != nil {
+			// 和 OpenSSH 一样，转发失败不影响登录
+			_, _ = io.WriteString(os.Stderr, "agent forwarding unavailable: "+err.Error()+"\r\n")
+		}
+	}
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		w, h, err := term.GetSize(fd)
+		if err != nil {
+			w, h = 80, 24
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 14400, ssh.TTY_OP_OSPEED: 14400}
+		if err := s.RequestPty(termType, h, w, modes); err != nil {
+			return -1, err
+		}
+		old, err := term.MakeRaw(fd)
+		if err != nil {
+			return -1, err
+		}
+		defer term.Restore(fd, old)
+
+		stop := watchResize(fd, func(w, h int) { _ = s.WindowChange(h, w) })
+		defer stop()
+	}
+
+	s.Stdin, s.Stdout, s.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if opts.Command != "" {
+		err = s.Start(opts.Command)
+	} else {
+		err = s.Shell()
+	}
+	if err != nil {
+		return -1, err
+	}
+	code, err := exitStatus(s.Wait())
+	// 远端正常退出但没有带 exit-status（部分设备），按 0 处理
+	var em *ssh.ExitMissingError
+	if errors.As(err, &em) {
+		return 0, nil
+	}
+	return code, err
+}
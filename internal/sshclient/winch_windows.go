@@ -0,0 +1,31 @@
+//go:build windows
+
+package sshclient
+
+import (
+	"time"
+
+	"golang.org/x/term"
+)
+
+// watchResize 在 Windows 上没有 SIGWINCH，定时轮询窗口大小
+func watchResize(fd int, fn func(w, h int)) (stop func()) {
+	w0, h0, _ := term.GetSize(fd)
+	tick := time.NewTicker(500 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer tick.Stop()
+		for {
+			select {
+			case <-tick.C:
+				if w, h, err := term.GetSize(fd); err == nil && (w != w0 || h != h0) {
+					w0, h0 = w, h
+					fn(w, h)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
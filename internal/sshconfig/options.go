@@ -0,0 +1,141 @@
+package sshconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Option is one ssh_config keyword with its raw value, e.g.
+// {"ServerAliveInterval", "30"} or {"SetEnv", "LANG=C TZ=UTC"}.
+type Option struct {
+	Key   string
+	Value string
+}
+
+// keywords 是 ssh_config(5) 的客户端关键字（OpenSSH 9.x），按规范大小写
+var keywords = []string{
+	"AddKeysToAgent", "AddressFamily", "BatchMode", "BindAddress", "BindInterface",
+	"CanonicalDomains", "CanonicalizeFallbackLocal", "CanonicalizeHostname",
+	"CanonicalizeMaxDots", "CanonicalizePermittedCNAMEs", "CASignatureAlgorithms",
+	"CertificateFile", "ChannelTimeout", "CheckHostIP", "Ciphers", "ClearAllForwardings",
+	"Compression", "ConnectionAttempts", "ConnectTimeout", "ControlMaster", "ControlPath",
+	"ControlPersist", "DynamicForward", "EnableEscapeCommandline", "EnableSSHKeysign",
+	"EscapeChar", "ExitOnForwardFailure", "FingerprintHash", "ForkAfterAuthentication",
+	"ForwardAgent", "ForwardX11", "ForwardX11Timeout", "ForwardX11Trusted", "GatewayPorts",
+	"GlobalKnownHostsFile", "GSSAPIAuthentication", "GSSAPIDelegateCredentials",
+	"HashKnownHosts", "Host", "HostbasedAcceptedAlgorithms", "HostbasedAuthentication",
+	"HostKeyAlgorithms", "HostKeyAlias", "HostName", "IdentitiesOnly", "IdentityAgent",
+	"IdentityFile", "IgnoreUnknown", "Include", "IPQoS", "KbdInteractiveAuthentication",
+	"KbdInteractiveDevices", "KexAlgorithms", "KnownHostsCommand", "LocalCommand",
+	"LocalForward", "LogLevel", "LogVerbose", "MACs", "Match",
+	"NoHostAuthenticationForLocalhost", "NumberOfPasswordPrompts", "ObscureKeystrokeTiming",
+	"PasswordAuthentication", "PermitLocalCommand", "PermitRemoteOpen", "PKCS11Provider",
+	"Port", "PreferredAuthentications", "ProxyCommand", "ProxyJump", "ProxyUseFdpass",
+	"PubkeyAcceptedAlgorithms", "PubkeyAuthentication", "RekeyLimit", "RemoteCommand",
+	"RemoteForward", "RequestTTY", "RequiredRSASize", "RevokedHostKeys",
+	"SecurityKeyProvider", "SendEnv", "ServerAliveCountMax", "ServerAliveInterval",
+	"SessionType", "SetEnv", "StdinNull", "StreamLocalBindMask", "StreamLocalBindUnlink",
+	"StrictHostKeyChecking", "SyslogFacility", "TCPKeepAlive", "Tag", "Tunnel",
+	"TunnelDevice", "UpdateHostKeys", "User", "UserKnownHostsFile", "VerifyHostKeyDNS",
+	"VisualHostKey", "XAuthLocation",
+}
+
+var canonical = func() map[string]string {
+	m := make(map[string]string, len(keywords))
+	for _, k := range keywords {
+		m[strings.ToLower(k)] = k
+	}
+	return m
+}()
+
+// managed 是 sshmgr 自己的字段，不能作为选项设置
+var managed = map[string]string{
+	"host":      "it is the sshmgr name",
+	"match":     "it is not a per-host option",
+	"include":   "it is not a per-host option",
+	"hostname":  "use `sshmgr add --host`",
+	"user":      "use `sshmgr add --user`",
+	"port":      "use `sshmgr add --port`",
+	"proxyjump": "use `sshmgr jump set`",
+}
+
+// sessionOnly 只对交互式会话有意义，批量执行/探测时去掉
+var sessionOnly = map[string]bool{
+	"remotecommand":           true,
+	"requesttty":              true,
+	"localforward":            true,
+	"remoteforward":           true,
+	"dynamicforward":          true,
+	"localcommand":            true,
+	"permitlocalcommand":      true,
+	"forkafterauthentication": true,
+	"sessiontype":             true,
+	"stdinnull":               true,
+}
+
+// 值是路径的关键字，含空格时要加引号
+var pathValued = map[string]bool{
+	"identityfile":       true,
+	"certificatefile":    true,
+	"controlpath":        true,
+	"identityagent":      true,
+	"userknownhostsfile": true,
+	"xauthlocation":      true,
+}
+
+// CanonicalKey returns the ssh_config spelling of key, or an error if key is
+// unknown or one of the fields sshmgr manages itself (HostName, User, Port,
+// ProxyJump, ...).
+func CanonicalKey(key string) (string, error) {
+	lk := strings.ToLower(strings.TrimSpace(key))
+	if why, ok := managed[lk]; ok {
+		return "", fmt.Errorf("%s cannot be set as an option: %s", key, why)
+	}
+	k, ok := canonical[lk]
+	if !ok {
+		return "", fmt.Errorf("unknown ssh option %q (see ssh_config(5))", key)
+	}
+	return k, nil
+}
+
+// Merge returns base overridden by over (matched case-insensitively),
+// sorted by key.
+func Merge(base, over []Option) []Option {
+	m := map[string]Option{}
+	for _, list := range [][]Option{base, over} {
+		for _, o := range list {
+			m[strings.ToLower(o.Key)] = o
+		}
+	}
+	out := make([]Option, 0, len(m))
+	for _, o := range m {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// NonInteractive drops options that only make sense for an interactive
+// session (RemoteCommand, RequestTTY, forwardings, ...).
+func NonInteractive(opts []Option) []Option {
+	var out []Option
+	for _, o := range opts {
+		if !sessionOnly[strings.ToLower(o.Key)] {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// Arg formats o for ssh -o.
+func (o Option) Arg() string {
+	return o.Key + "=" + o.value()
+}
+
+func (o Option) value() string {
+	if pathValued[strings.ToLower(o.Key)] {
+		return quote(o.Value)
+	}
+	return o.Value
+}
@@ -0,0 +1,257 @@
+// Package sshconfig reads OpenSSH client config files (ssh_config(5)).
+//
+// Only the keywords sshmgr stores are interpreted: HostName, User, Port,
+// IdentityFile and ProxyJump. Include is followed (with globs); Match blocks
+// cannot be mapped to a single host and are skipped.
+package sshconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Host is the effective config of one concrete Host alias.
+type Host struct {
+	Alias        string
+	HostName     string
+	User         string
+	Port         int
+	IdentityFile string // first IdentityFile only
+	ProxyJump    string
+	Source       string // file:line of the Host line that introduced the alias
+
+	// 仅 Render 使用
+	LastIP       string
+	ProxyCommand string
+	Options      []Option
+}
+
+// Result of parsing a config tree.
+type Result struct {
+	Hosts        []Host
+	MatchBlocks  int      // Match blocks skipped
+	Files        []string // every file read, Include targets included
+	WildcardOnly []string // patterns that never name a concrete host, e.g. "*.lab"
+}
+
+type block struct {
+	patterns []string
+	opts     map[string]string
+	source   string
+	match    bool
+	cont     bool // Include 之后接续的同一个块，见 parseInto
+}
+
+// Include 最大嵌套深度，防止循环引用
+const maxIncludeDepth = 16
+
+// ParseFile parses path and everything it Includes.
+func ParseFile(p string) (*Result, error) {
+	p = expandHome(p)
+	res := &Result{}
+	// 文件开头到第一个 Host 之间的选项等同于 "Host *"
+	blocks := []*block{{patterns: []string{"*"}, opts: map[string]string{}, source: p + ":0"}}
+	if err := parseInto(p, filepath.Dir(p), &blocks, res, 0); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, b := range blocks {
+		if b.cont {
+			continue
+		}
+		if b.match {
+			res.MatchBlocks++
+			continue
+		}
+		for _, pat := range b.patterns {
+			if isWildcard(pat) {
+				if pat != "*" {
+					res.WildcardOnly = append(res.WildcardOnly, pat)
+				}
+				continue
+			}
+			if seen[pat] {
+				continue
+			}
+			seen[pat] = true
+			res.Hosts = append(res.Hosts, resolve(pat, b.source, blocks))
+		}
+	}
+	return res, nil
+}
+
+// resolve 按 ssh 的规则：按文件顺序匹配所有 Host 块，每个选项取第一次出现的值。
+func resolve(alias, source string, blocks []*block) Host {
+	h := Host{Alias: alias, Source: source}
+	get := map[string]string{}
+	for _, b := range blocks {
+		if b.match || !matches(alias, b.patterns) {
+			continue
+		}
+		for k, v := range b.opts {
+			if _, ok := get[k]; !ok {
+				get[k] = v
+			}
+		}
+	}
+
+	h.HostName = strings.ReplaceAll(get["hostname"], "%h", alias)
+	if h.HostName == "" {
+		h.HostName = alias
+	}
+	h.User = get["user"]
+	h.Port = 22
+	if v, err := strconv.Atoi(get["port"]); err == nil && v > 0 {
+		h.Port = v
+	}
+	h.IdentityFile = get["identityfile"]
+	if strings.EqualFold(get["proxyjump"], "none") {
+		h.ProxyJump = ""
+	} else {
+		h.ProxyJump = get["proxyjump"]
+	}
+	return h
+}
+
+func parseInto(p, baseDir string, blocks *[]*block, res *Result, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: Include nested too deeply", p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	res.Files = append(res.Files, p)
+
+	// 被 Include 的文件在第一个 Host 之前的选项属于 Include 所在的块
+	cur := (*blocks)[len(*blocks)-1]
+
+	sc := bufio.NewScanner(f)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		key, args := splitLine(sc.Text())
+		if key == "" {
+			continue
+		}
+		src := fmt.Sprintf("%s:%d", p, lineNo)
+
+		switch key {
+		case "host":
+			cur = &block{patterns: args, opts: map[string]string{}, source: src}
+			*blocks = append(*blocks, cur)
+		case "match":
+			cur = &block{opts: map[string]string{}, source: src, match: true}
+			*blocks = append(*blocks, cur)
+		case "include":
+			for _, pat := range args {
+				pat = expandHome(pat)
+				if !filepath.IsAbs(pat) {
+					pat = filepath.Join(baseDir, pat)
+				}
+				files, err := filepath.Glob(pat)
+				if err != nil {
+					return fmt.Errorf("%s: bad Include pattern %q: %w", src, pat, err)
+				}
+				for _, inc := range files {
+					if err := parseInto(inc, baseDir, blocks, res, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			// 和 ssh 一样，Include 之后的选项仍属于 Include 所在的块。接一个同样
+			// 模式的续块而不是写回原块，这样选项在文件里的先后顺序（先出现者优先）不变。
+			if last := (*blocks)[len(*blocks)-1]; last != cur {
+				cur = &block{patterns: cur.patterns, opts: map[string]string{}, source: cur.source, match: cur.match, cont: true}
+				*blocks = append(*blocks, cur)
+			}
+		default:
+			if len(args) == 0 {
+				continue
+			}
+			if _, ok := cur.opts[key]; !ok {
+				cur.opts[key] = strings.Join(args, " ")
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// splitLine 解析 "Key value"、"Key=value" 以及带双引号的参数；key 转小写。
+func splitLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), nil
+	}
+	key := strings.ToLower(line[:i])
+	rest := strings.TrimLeft(line[i:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	var args []string
+	var cur strings.Builder
+	inQuote, has := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			has = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if has {
+				args = append(args, cur.String())
+				cur.Reset()
+				has = false
+			}
+		default:
+			cur.WriteRune(r)
+			has = true
+		}
+	}
+	if has {
+		args = append(args, cur.String())
+	}
+	return key, args
+}
+
+func matches(alias string, patterns []string) bool {
+	ok := false
+	for _, p := range patterns {
+		neg := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		if m, _ := path.Match(strings.ToLower(p), strings.ToLower(alias)); m {
+			if neg {
+				return false
+			}
+			ok = true
+		}
+	}
+	return ok
+}
+
+func isWildcard(p string) bool {
+	return strings.ContainsAny(p, "*?!")
+}
+
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	return p
+}
+
+// DefaultPath is ~/.ssh/config.
+func DefaultPath() string {
+	return expandHome("~/.ssh/config")
+}
@@ -0,0 +1,177 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func hostsByAlias(t *testing.T, res *Result) map[string]Host {
+	t.Helper()
+	out := map[string]Host{}
+	for _, h := range res.Hosts {
+		out[h.Alias] = h
+	}
+	return out
+}
+
+func TestParseIncludeKeepsParentBlock(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config": `
+Host a
+  HostName a.example
+  Include inc.conf
+  User alice
+
+Host c
+  HostName c.example
+`,
+		// 第一个 Host 之前的选项属于 Include 所在的 Host a
+		"inc.conf": `
+Port 2222
+Host b
+  HostName b.example
+`,
+	})
+
+	res, err := ParseFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := hostsByAlias(t, res)
+	if len(hosts) != 3 {
+		t.Fatalf("hosts = %v", res.Hosts)
+	}
+	if a := hosts["a"]; a.User != "alice" || a.Port != 2222 || a.HostName != "a.example" {
+		t.Errorf("a = %+v, want User alice, Port 2222", a)
+	}
+	if b := hosts["b"]; b.User != "" || b.Port != 22 {
+		t.Errorf("b = %+v: options after the Include leaked into the included block", b)
+	}
+	if c := hosts["c"]; c.User != "" {
+		t.Errorf("c = %+v", c)
+	}
+	if a := hosts["a"]; a.Source != filepath.Join(dir, "config")+":2" {
+		t.Errorf("a.Source = %s", a.Source)
+	}
+}
+
+func TestParseIncludeKeepsFirstValueOrder(t *testing.T) {
+	// ssh 取第一次出现的值：Include 里的 "Host *" 在 User alice 之前，所以它生效
+	dir := writeFiles(t, map[string]string{
+		"config": `
+Host a
+  Include inc.conf
+  User alice
+  Port 2200
+`,
+		"inc.conf": `
+Host *
+  User star
+`,
+	})
+
+	res, err := ParseFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := hostsByAlias(t, res)["a"]
+	if a.User != "star" || a.Port != 2200 {
+		t.Errorf("a = %+v, want User star, Port 2200", a)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config": `
+# 全局
+User root
+
+Host web1 web2
+  HostName %h.example.com
+  Port=2022
+  IdentityFile "~/.ssh/id web"
+
+Host *.lab
+  ProxyJump bastion
+
+Match host foo
+  User nobody
+
+Host db.lab
+  ProxyJump none
+  User dba
+`,
+	})
+
+	res, err := ParseFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MatchBlocks != 1 {
+		t.Errorf("MatchBlocks = %d, want 1", res.MatchBlocks)
+	}
+	if len(res.WildcardOnly) != 1 || res.WildcardOnly[0] != "*.lab" {
+		t.Errorf("WildcardOnly = %v", res.WildcardOnly)
+	}
+
+	hosts := hostsByAlias(t, res)
+	tests := []Host{
+		{Alias: "web1", HostName: "web1.example.com", User: "root", Port: 2022, IdentityFile: "~/.ssh/id web"},
+		{Alias: "web2", HostName: "web2.example.com", User: "root", Port: 2022, IdentityFile: "~/.ssh/id web"},
+		// Host *.lab 在前，ProxyJump bastion 先出现
+		{Alias: "db.lab", HostName: "db.lab", User: "root", Port: 22, ProxyJump: "bastion"},
+	}
+	for _, want := range tests {
+		got, ok := hosts[want.Alias]
+		if !ok {
+			t.Errorf("%s missing", want.Alias)
+			continue
+		}
+		got.Source = ""
+		if got.HostName != want.HostName || got.User != want.User || got.Port != want.Port ||
+			got.IdentityFile != want.IdentityFile || got.ProxyJump != want.ProxyJump {
+			t.Errorf("%s = %+v, want %+v", want.Alias, got, want)
+		}
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		line string
+		key  string
+		args []string
+	}{
+		{"", "", nil},
+		{"  # comment", "", nil},
+		{"HostName example.com", "hostname", []string{"example.com"}},
+		{"Port=2222", "port", []string{"2222"}},
+		{"Port = 2222", "port", []string{"2222"}},
+		{`IdentityFile "/a b/key"`, "identityfile", []string{"/a b/key"}},
+		{"Host a b\tc", "host", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		key, args := splitLine(tt.line)
+		if key != tt.key || len(args) != len(tt.args) {
+			t.Errorf("splitLine(%q) = %q %q, want %q %q", tt.line, key, args, tt.key, tt.args)
+			continue
+		}
+		for i := range args {
+			if args[i] != tt.args[i] {
+				t.Errorf("splitLine(%q) = %q %q, want %q %q", tt.line, key, args, tt.key, tt.args)
+				break
+			}
+		}
+	}
+}
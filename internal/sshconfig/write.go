@@ -0,0 +1,144 @@
+package sshconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManagedMarker is the first line of every file written by Render; files
+// without it are never overwritten.
+const ManagedMarker = "# sshmgr-managed"
+
+// Render writes one Host block per entry. header lines are emitted as comments
+// right after the marker. LastIP is written as a comment; ProxyCommand, when
+// set and ProxyJump is empty, is emitted as a real option, followed by Options
+// minus the session-only ones (see NonInteractive): the file is also read by
+// scp, rsync and IDEs, which break on RemoteCommand or RequestTTY.
+func Render(hosts []Host, header ...string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s: do not edit, regenerate with `sshmgr export ssh-config`\n", ManagedMarker)
+	for _, h := range header {
+		fmt.Fprintf(&b, "# %s\n", h)
+	}
+
+	for _, h := range hosts {
+		fmt.Fprintf(&b, "\nHost %s\n", h.Alias)
+		fmt.Fprintf(&b, "  HostName %s\n", h.HostName)
+		if h.LastIP != "" {
+			fmt.Fprintf(&b, "  # last_ip %s\n", h.LastIP)
+		}
+		if h.User != "" {
+			fmt.Fprintf(&b, "  User %s\n", h.User)
+		}
+		if h.Port != 0 && h.Port != 22 {
+			fmt.Fprintf(&b, "  Port %d\n", h.Port)
+		}
+		if h.IdentityFile != "" {
+			fmt.Fprintf(&b, "  IdentityFile %s\n", quote(h.IdentityFile))
+		}
+		// ProxyJump 和 ProxyCommand 互斥，谁先出现谁生效，这里只写一个
+		if h.ProxyJump != "" {
+			fmt.Fprintf(&b, "  ProxyJump %s\n", h.ProxyJump)
+		} else if h.ProxyCommand != "" {
+			fmt.Fprintf(&b, "  ProxyCommand %s\n", h.ProxyCommand)
+		}
+		for _, o := range NonInteractive(h.Options) {
+			fmt.Fprintf(&b, "  %s %s\n", o.Key, o.value())
+		}
+	}
+	return b.Bytes()
+}
+
+// ReadHeader returns the "# key: value" header of a managed file, or
+// ok=false if the file is missing or not managed by sshmgr.
+func ReadHeader(path string) (map[string]string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() || !strings.HasPrefix(sc.Text(), ManagedMarker) {
+		return nil, false
+	}
+	out := map[string]string{}
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "# ") {
+			break
+		}
+		if k, v, ok := strings.Cut(strings.TrimPrefix(line, "# "), ": "); ok {
+			out[k] = v
+		}
+	}
+	return out, true
+}
+
+// WriteManaged replaces path with data unless path exists and is not ours.
+// It reports whether the content changed.
+func WriteManaged(path string, data []byte) (bool, error) {
+	old, err := os.ReadFile(path)
+	if err == nil {
+		if !bytes.HasPrefix(old, []byte(ManagedMarker)) {
+			return false, fmt.Errorf("%s exists and is not managed by sshmgr, refusing to overwrite", path)
+		}
+		if bytes.Equal(old, data) {
+			return false, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp, path)
+}
+
+// EnsureInclude prepends "Include <target>" to the ssh config at cfgPath
+// unless an Include line for target is already present. Include has to come
+// before the first Host block to apply to every host.
+func EnsureInclude(cfgPath, target string) (bool, error) {
+	cfgPath = expandHome(cfgPath)
+	old, err := os.ReadFile(cfgPath)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(old), "\n") {
+		key, args := splitLine(line)
+		if key != "include" {
+			continue
+		}
+		for _, a := range args {
+			if expandHome(a) == target || filepath.Join(filepath.Dir(cfgPath), a) == target {
+				return false, nil
+			}
+		}
+	}
+
+	line := "Include " + quote(target) + "\n\n"
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o700); err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(cfgPath, append([]byte(line), old...), 0o600)
+}
+
+func quote(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// DefaultExportPath is ~/.ssh/config.d/sshmgr.
+func DefaultExportPath() string {
+	return expandHome("~/.ssh/config.d/sshmgr")
+}
@@ -7,14 +7,32 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
+// HostKey is one public host key offered by a server.
+type HostKey struct {
+	Type        string // e.g. ssh-ed25519
+	Fingerprint string // SHA256:...
+}
+
 // HostKeyFingerprint fetches SSH host key and returns SHA256 fingerprint.
 func HostKeyFingerprint(ip string) (string, error) {
+	keys, err := HostKeys(ip, 22)
+	if err != nil {
+		return "", err
+	}
+	return keys[0].Fingerprint, nil
+}
+
+// HostKeys fetches all ed25519/ecdsa/rsa host keys via ssh-keyscan and
+// returns their SHA256 fingerprints, one per key type.
+func HostKeys(ip string, port int) ([]HostKey, error) {
 	cmd := exec.Command(
 		"ssh-keyscan",
 		"-T", "2",
+		"-p", strconv.Itoa(port),
 		"-t", "ed25519,ecdsa,rsa",
 		ip,
 	)
@@ -24,13 +42,19 @@ func HostKeyFingerprint(ip string) (string, error) {
 	cmd.Stderr = nil
 
 	if err := cmd.Run(); err != nil {
-		return "", err
+		return nil, err
 	}
 
+	var keys []HostKey
+	seen := map[string]bool{}
 	scanner := bufio.NewScanner(&out)
 	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
-		if len(fields) < 3 {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || seen[fields[1]] {
 			continue
 		}
 
@@ -40,9 +64,14 @@ func HostKeyFingerprint(ip string) (string, error) {
 		}
 
 		sum := sha256.Sum256(rawKey)
-		fp := base64.StdEncoding.EncodeToString(sum[:])
-		return "SHA256:" + fp, nil
+		// 与 ssh-keygen -l 一致：去掉 base64 padding
+		fp := strings.TrimRight(base64.StdEncoding.EncodeToString(sum[:]), "=")
+		keys = append(keys, HostKey{Type: fields[1], Fingerprint: "SHA256:" + fp})
+		seen[fields[1]] = true
 	}
 
-	return "", fmt.Errorf("no ssh hostkey found")
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no ssh hostkey found")
+	}
+	return keys, nil
 }
@@ -6,12 +6,13 @@ import (
 	"strings"
 )
 
-// RemoteHostname attempts to execute hostname via ssh.
+// RemoteHostname attempts to execute hostname via ssh on ip:port.
 // Requires user to have password/key ready. otherwise returns error.
-func RemoteHostname(user, ip string) (string, error) {
+func RemoteHostname(user, ip string, port int) (string, error) {
 	out, err := exec.Command(
 		"ssh",
 		"-o", "BatchMode=yes",
+		"-p", fmt.Sprint(port),
 		fmt.Sprintf("%s@%s", user, ip),
 		"hostname",
 	).Output()
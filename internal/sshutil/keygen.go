@@ -0,0 +1,125 @@
+package sshutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// UserKey is a generated or loaded login key.
+type UserKey struct {
+	Path        string // private key file; the public key is Path + ".pub"
+	Type        string // e.g. ssh-ed25519
+	Fingerprint string // SHA256:...
+	PublicKey   string // authorized_keys line, without trailing newline
+}
+
+// GenerateKey creates a new unencrypted key pair of kind ed25519, ecdsa or
+// rsa at path (mode 0600) and path.pub, like ssh-keygen -N "". It refuses
+// to overwrite an existing file.
+func GenerateKey(path, kind, comment string) (UserKey, error) {
+	var priv crypto.PrivateKey
+	var err error
+	switch kind {
+	case "", "ed25519":
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	case "ecdsa":
+		priv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "rsa":
+		priv, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return UserKey{}, fmt.Errorf("unknown key type %q (want ed25519|ecdsa|rsa)", kind)
+	}
+	if err != nil {
+		return UserKey{}, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return UserKey{}, err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return UserKey{}, err
+	}
+	k := userKey(path, signer.PublicKey(), comment)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return UserKey{}, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return UserKey{}, err
+	}
+	if err := pem.Encode(f, block); err != nil {
+		f.Close()
+		return UserKey{}, err
+	}
+	if err := f.Close(); err != nil {
+		return UserKey{}, err
+	}
+	if err := os.WriteFile(path+".pub", []byte(k.PublicKey+"\n"), 0o644); err != nil {
+		return UserKey{}, err
+	}
+	return k, nil
+}
+
+// LoadKey reads the public half of the key at path (~/ is expanded), from
+// path.pub or, if that is missing, from the (unencrypted) private key.
+func LoadKey(path string) (UserKey, error) {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if b, err := os.ReadFile(path + ".pub"); err == nil {
+		pub, comment, _, _, err := ssh.ParseAuthorizedKey(b)
+		if err != nil {
+			return UserKey{}, fmt.Errorf("%s.pub: %w", path, err)
+		}
+		return userKey(path, pub, comment), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return UserKey{}, err
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	if err != nil {
+		return UserKey{}, fmt.Errorf("%s: %w (encrypted keys need a .pub file next to them)", path, err)
+	}
+	return userKey(path, signer.PublicKey(), ""), nil
+}
+
+func userKey(path string, pub ssh.PublicKey, comment string) UserKey {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+	if comment != "" {
+		line += " " + comment
+	}
+	return UserKey{
+		Path:        path,
+		Type:        pub.Type(),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		PublicKey:   line,
+	}
+}
+
+// KeyBlob returns the base64 field of an authorized_keys line, which
+// identifies the key regardless of options and comment.
+func KeyBlob(line string) string {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		return ""
+	}
+	f := strings.Fields(strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))))
+	return f[len(f)-1]
+}
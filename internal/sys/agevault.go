@@ -0,0 +1,115 @@
+package sys
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sshmgr/internal/app"
+)
+
+// ageVault is a local file vault: a JSON map name -> user -> password,
+// encrypted as a whole with the age CLI.
+type ageVault struct {
+	Path     string
+	Identity string
+}
+
+func newAgeVault() ageVault {
+	id := os.Getenv("SSHMGR_AGE_IDENTITY")
+	if id == "" {
+		id = filepath.Join(app.ConfigDir(), "age-identity.txt")
+	}
+	return ageVault{
+		Path:     filepath.Join(app.ConfigDir(), "vault.age"),
+		Identity: id,
+	}
+}
+
+func (ageVault) Name() string { return "age" }
+
+func (v ageVault) load() (map[string]map[string]string, error) {
+	m := map[string]map[string]string{}
+	if _, err := os.Stat(v.Path); os.IsNotExist(err) {
+		return m, nil
+	}
+	out, err := runSecretCmd("", "age", "-d", "-i", v.Identity, v.Path)
+	if err != nil {
+		return nil, fmt.Errorf("age vault decrypt failed: %w", err)
+	}
+	if err := json.Unmarshal([]byte(out), &m); err != nil {
+		return nil, fmt.Errorf("age vault corrupt: %w", err)
+	}
+	return m, nil
+}
+
+func (v ageVault) save(m map[string]map[string]string) error {
+	if err := v.ensureIdentity(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// 先写临时文件再 rename，避免加密失败时把旧 vault 截断
+	tmp := v.Path + ".tmp"
+	if _, err := runSecretCmd(string(data), "age", "-e", "-i", v.Identity, "-o", tmp); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("age vault encrypt failed: %w", err)
+	}
+	return os.Rename(tmp, v.Path)
+}
+
+func (v ageVault) ensureIdentity() error {
+	if _, err := os.Stat(v.Identity); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(v.Identity), 0o700); err != nil {
+		return err
+	}
+	if _, err := runSecretCmd("", "age-keygen", "-o", v.Identity); err != nil {
+		return fmt.Errorf("age-keygen failed: %w", err)
+	}
+	return os.Chmod(v.Identity, 0o600)
+}
+
+func (v ageVault) Set(name, user, password string) error {
+	m, err := v.load()
+	if err != nil {
+		return err
+	}
+	if m[name] == nil {
+		m[name] = map[string]string{}
+	}
+	m[name][user] = password
+	return v.save(m)
+}
+
+func (v ageVault) Get(name, user string) (string, error) {
+	m, err := v.load()
+	if err != nil {
+		return "", err
+	}
+	pw, ok := m[name][user]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return pw, nil
+}
+
+func (v ageVault) Delete(name, user string) error {
+	m, err := v.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := m[name][user]; !ok {
+		return ErrSecretNotFound
+	}
+	delete(m[name], user)
+	if len(m[name]) == 0 {
+		delete(m, name)
+	}
+	return v.save(m)
+}
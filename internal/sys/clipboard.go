@@ -1,22 +1,188 @@
 package sys
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"os/exec"
-	"strconv"
+	"runtime"
 	"strings"
+
+	"golang.org/x/term"
 )
 
-func ClipboardCopy(text string) error {
-	cmd := exec.Command("pbcopy")
-	cmd.Stdin = strings.NewReader(text)
-	return cmd.Run()
+// ErrClipboardUnreadable is returned by Read when the backend can only
+// write (OSC52), so the clipboard cannot be compared before clearing.
+var ErrClipboardUnreadable = errors.New("clipboard cannot be read back")
+
+// Clipboard is the system clipboard reached through an external tool or
+// the terminal.
+type Clipboard interface {
+	Name() string
+	Copy(text string) error
+	Read() (string, error)
+	Clear() error
+}
+
+// ClipboardBackends lists the accepted values for NewClipboard.
+var ClipboardBackends = []string{"auto", "pbcopy", "wl-copy", "xclip", "xsel", "osc52"}
+
+// NewClipboard returns the backend by name; "auto" picks the first usable
+// one: pbcopy on macOS, wl-copy under Wayland, xclip/xsel under X11, else
+// OSC52 if there is a terminal.
+func NewClipboard(backend string) (Clipboard, error) {
+	if backend == "" || backend == "auto" {
+		var err error
+		if backend, err = autoClipboard(); err != nil {
+			return nil, err
+		}
+	}
+	switch backend {
+	case "pbcopy":
+		return toolClipboard{name: backend,
+			copy: []string{"pbcopy"}, paste: []string{"pbpaste"}, clear: []string{"pbcopy"}}, nil
+	case "wl-copy":
+		return toolClipboard{name: backend,
+			copy:  []string{"wl-copy", "--type", "text/plain"},
+			paste: []string{"wl-paste", "--no-newline", "--type", "text/plain"},
+			clear: []string{"wl-copy", "--clear"}}, nil
+	case "xclip":
+		return toolClipboard{name: backend,
+			copy:  []string{"xclip", "-selection", "clipboard", "-in"},
+			paste: []string{"xclip", "-selection", "clipboard", "-out"},
+			clear: []string{"xclip", "-selection", "clipboard", "-in"}}, nil
+	case "xsel":
+		return toolClipboard{name: backend,
+			copy:  []string{"xsel", "--clipboard", "--input"},
+			paste: []string{"xsel", "--clipboard", "--output"},
+			clear: []string{"xsel", "--clipboard", "--clear"}}, nil
+	case "osc52":
+		return osc52Clipboard{}, nil
+	default:
+		return nil, fmt.Errorf("unknown clipboard backend: %s (want %s)", backend, strings.Join(ClipboardBackends, "|"))
+	}
+}
+
+func autoClipboard() (string, error) {
+	has := func(tool string) bool {
+		_, err := exec.LookPath(tool)
+		return err == nil
+	}
+	switch {
+	case runtime.GOOS == "darwin":
+		return "pbcopy", nil
+	case os.Getenv("WAYLAND_DISPLAY") != "" && has("wl-copy") && has("wl-paste"):
+		return "wl-copy", nil
+	case os.Getenv("DISPLAY") != "" && has("xclip"):
+		return "xclip", nil
+	case os.Getenv("DISPLAY") != "" && has("xsel"):
+		return "xsel", nil
+	}
+	if f, err := openTerminal(); err == nil {
+		f.Close()
+		return "osc52", nil
+	}
+	return "", errors.New("no clipboard available (install wl-clipboard, xclip or xsel, or use a terminal with OSC52)")
+}
+
+// ClearIfUnchanged clears c only if it still holds the text whose SHA-256
+// is sum, so anything the user copied since is left alone.
+func ClearIfUnchanged(c Clipboard, sum [sha256.Size]byte) (bool, error) {
+	cur, err := c.Read()
+	if err != nil {
+		return false, err
+	}
+	if sha256.Sum256([]byte(cur)) != sum {
+		return false, nil
+	}
+	return true, c.Clear()
+}
+
+type toolClipboard struct {
+	name               string
+	copy, paste, clear []string
+}
+
+func (c toolClipboard) Name() string { return c.name }
+
+func (c toolClipboard) Copy(text string) error {
+	return runClipboard(c.copy, text)
 }
 
-func ClipboardClearAfter(ttlSeconds int) error {
-	if ttlSeconds <= 0 {
-		return nil
+func (c toolClipboard) Read() (string, error) {
+	cmd := exec.Command(c.paste[0], c.paste[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// 剪贴板为空时 wl-paste 返回非 0
+		if c.name == "wl-copy" && strings.Contains(stderr.String(), "No selection") {
+			return "", nil
+		}
+		return "", fmt.Errorf("%s: %w: %s", c.paste[0], err, strings.TrimSpace(stderr.String()))
 	}
-	script := "sleep " + strconv.Itoa(ttlSeconds) + "; printf \"\" | pbcopy"
-	cmd := exec.Command("sh", "-c", script)
-	return cmd.Start()
+	return string(out), nil
 }
+
+func (c toolClipboard) Clear() error {
+	return runClipboard(c.clear, "")
+}
+
+// runClipboard 内容只走 stdin。wl-copy/xclip 会留一个后台进程持有剪贴板，
+// stdout/stderr 不能接管道，否则 Run 要等它退出。
+func runClipboard(argv []string, stdin string) error {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
+
+// osc52Clipboard 通过终端转义序列设置剪贴板（也适用于 ssh 远程会话）。
+// 终端不会把内容返回给程序，所以不能读回比较。
+type osc52Clipboard struct{}
+
+func (osc52Clipboard) Name() string { return "osc52" }
+
+func (osc52Clipboard) Copy(text string) error {
+	return writeOSC52(base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+func (osc52Clipboard) Read() (string, error) { return "", ErrClipboardUnreadable }
+
+func (osc52Clipboard) Clear() error { return writeOSC52("") }
+
+func writeOSC52(payload string) error {
+	f, err := openTerminal()
+	if err != nil {
+		return fmt.Errorf("osc52: %w", err)
+	}
+	defer f.Close()
+	seq := "\x1b]52;c;" + payload + "\a"
+	// tmux 需要 passthrough 包装（内部的 ESC 要写两次）
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	_, err = io.WriteString(f, seq)
+	return err
+}
+
+// openTerminal 打开控制终端；没有 /dev/tty（Windows）时用 stderr
+func openTerminal() (io.WriteCloser, error) {
+	if f, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		return f, nil
+	}
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		return nopCloser{os.Stderr}, nil
+	}
+	return nil, errors.New("no terminal")
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
@@ -1,6 +1,7 @@
 package sys
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -8,6 +9,20 @@ import (
 
 func service(name string) string { return "sshmgr:" + name }
 
+// security 在条目不存在时返回 44
+const keychainNotFoundExit = 44
+
+// keychainStore is the macOS Keychain backend (via /usr/bin/security).
+type keychainStore struct{}
+
+func (keychainStore) Name() string { return "keychain" }
+
+func (keychainStore) Set(name, user, password string) error { return KeychainSet(name, user, password) }
+
+func (keychainStore) Get(name, user string) (string, error) { return KeychainGet(name, user) }
+
+func (keychainStore) Delete(name, user string) error { return KeychainDelete(name, user) }
+
 func KeychainSet(name, user, password string) error {
 	cmd := exec.Command("security", "add-generic-password",
 		"-a", user,
@@ -30,6 +45,9 @@ func KeychainGet(name, user string) (string, error) {
 	)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
+		if isExitCode(err, keychainNotFoundExit) {
+			return "", ErrSecretNotFound
+		}
 		return "", fmt.Errorf("keychain get failed: %v: %s", err, strings.TrimSpace(string(out)))
 	}
 	return strings.TrimRight(string(out), "\n"), nil
@@ -42,7 +60,15 @@ func KeychainDelete(name, user string) error {
 	)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
+		if isExitCode(err, keychainNotFoundExit) {
+			return ErrSecretNotFound
+		}
 		return fmt.Errorf("keychain delete failed: %v: %s", err, strings.TrimSpace(string(out)))
 	}
 	return nil
 }
+
+func isExitCode(err error, code int) bool {
+	var ee *exec.ExitError
+	return errors.As(err, &ee) && ee.ExitCode() == code
+}
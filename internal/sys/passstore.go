@@ -0,0 +1,44 @@
+package sys
+
+import (
+	"fmt"
+	"strings"
+)
+
+// passStore keeps secrets in the standard unix password manager (pass + gpg),
+// one entry per host under sshmgr/<name>/<user>.
+type passStore struct{}
+
+func (passStore) Name() string { return "pass" }
+
+func passEntry(name, user string) string { return "sshmgr/" + name + "/" + user }
+
+func (passStore) Set(name, user, password string) error {
+	if _, err := runSecretCmd(password+"\n", "pass", "insert", "-m", "-f", passEntry(name, user)); err != nil {
+		return fmt.Errorf("pass set failed: %w", err)
+	}
+	return nil
+}
+
+func (passStore) Get(name, user string) (string, error) {
+	out, err := runSecretCmd("", "pass", "show", passEntry(name, user))
+	if err != nil {
+		if strings.Contains(err.Error(), "not in the password store") {
+			return "", ErrSecretNotFound
+		}
+		return "", fmt.Errorf("pass get failed: %w", err)
+	}
+	// pass 约定第一行是密码，后面是附加信息
+	pw, _, _ := strings.Cut(out, "\n")
+	return pw, nil
+}
+
+func (passStore) Delete(name, user string) error {
+	if _, err := runSecretCmd("", "pass", "rm", "-f", passEntry(name, user)); err != nil {
+		if strings.Contains(err.Error(), "not in the password store") {
+			return ErrSecretNotFound
+		}
+		return fmt.Errorf("pass delete failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package sys
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Detach makes cmd run in its own session so it survives the parent
+// terminal being closed.
+func Detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// ProcessAlive reports whether a process with pid exists.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,71 @@
+package sys
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrSecretNotFound is returned by Get when the backend has no entry for the host.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore stores one password per (sshmgr name, ssh user).
+type SecretStore interface {
+	Name() string
+	Set(name, user, password string) error
+	Get(name, user string) (string, error)
+	Delete(name, user string) error
+}
+
+// SecretBackends lists the accepted values for NewSecretStore.
+var SecretBackends = []string{"auto", "keychain", "secret-service", "pass", "age"}
+
+// NewSecretStore returns the backend by name; "auto" picks one for the current OS.
+func NewSecretStore(backend string) (SecretStore, error) {
+	if backend == "" || backend == "auto" {
+		backend = autoSecretBackend()
+	}
+	switch backend {
+	case "keychain":
+		return keychainStore{}, nil
+	case "secret-service":
+		return secretServiceStore{}, nil
+	case "pass":
+		return passStore{}, nil
+	case "age":
+		return newAgeVault(), nil
+	default:
+		return nil, fmt.Errorf("unknown secret backend: %s (want %s)", backend, strings.Join(SecretBackends, "|"))
+	}
+}
+
+func autoSecretBackend() string {
+	if runtime.GOOS == "darwin" {
+		return "keychain"
+	}
+	if _, err := exec.LookPath("secret-tool"); err == nil && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		return "secret-service"
+	}
+	if _, err := exec.LookPath("pass"); err == nil {
+		return "pass"
+	}
+	return "age"
+}
+
+// runSecretCmd 执行外部命令，密码只走 stdin，不出现在 argv 上。
+func runSecretCmd(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
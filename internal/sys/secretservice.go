@@ -0,0 +1,46 @@
+package sys
+
+import (
+	"fmt"
+	"strings"
+)
+
+// secretServiceStore talks to the freedesktop Secret Service (GNOME Keyring,
+// KWallet, ...) over D-Bus through libsecret's secret-tool.
+type secretServiceStore struct{}
+
+func (secretServiceStore) Name() string { return "secret-service" }
+
+func secretAttrs(name, user string) []string {
+	return []string{"service", service(name), "account", user}
+}
+
+func (secretServiceStore) Set(name, user, password string) error {
+	args := append([]string{"store", "--label=" + service(name) + " (" + user + ")"}, secretAttrs(name, user)...)
+	if _, err := runSecretCmd(password, "secret-tool", args...); err != nil {
+		return fmt.Errorf("secret-service set failed: %w", err)
+	}
+	return nil
+}
+
+func (secretServiceStore) Get(name, user string) (string, error) {
+	out, err := runSecretCmd("", "secret-tool", append([]string{"lookup"}, secretAttrs(name, user)...)...)
+	if err != nil {
+		// secret-tool lookup 找不到时 exit 1 且无输出
+		if isExitCode(err, 1) {
+			return "", ErrSecretNotFound
+		}
+		return "", fmt.Errorf("secret-service get failed: %w", err)
+	}
+	if out == "" {
+		return "", ErrSecretNotFound
+	}
+	return strings.TrimRight(out, "\n"), nil
+}
+
+func (secretServiceStore) Delete(name, user string) error {
+	if _, err := runSecretCmd("", "secret-tool", append([]string{"clear"}, secretAttrs(name, user)...)...); err != nil {
+		return fmt.Errorf("secret-service delete failed: %w", err)
+	}
+	return nil
+}
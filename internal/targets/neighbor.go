@@ -0,0 +1,89 @@
+package targets
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"sshmgr/internal/neigh"
+)
+
+// Neighbors6 returns the IPv6 neighbors of this machine (see package neigh).
+// Link-local addresses carry their zone.
+func Neighbors6(ctx context.Context) ([]netip.Addr, error) {
+	list, err := neigh.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []netip.Addr
+	for _, e := range list {
+		if e.IP.Is6() {
+			addrs = append(addrs, e.IP)
+		}
+	}
+	return addrs, nil
+}
+
+// solicit 在连着这些 IPv6 前缀的网卡上 ping ff02::1（所有节点），
+// 让在线的主机出现在邻居表里。失败不影响后续读表。
+func solicit(ctx context.Context, items []item) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	names := map[string]bool{}
+	for _, it := range items {
+		if !it.prefix.IsValid() || !it.prefix.Addr().Is6() {
+			continue
+		}
+		linkLocal := it.prefix.Addr().IsLinkLocalUnicast()
+		for _, ifi := range ifaces {
+			if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 || ifi.Flags&net.FlagMulticast == 0 {
+				continue
+			}
+			if linkLocal || ifaceInPrefix(ifi, it.prefix) {
+				names[ifi.Name] = true
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			dst := "ff02::1%" + name
+			var c *exec.Cmd
+			if runtime.GOOS == "linux" {
+				c = exec.CommandContext(ctx, "ping", "-6", "-c", "2", "-i", "0.5", "-w", "2", dst)
+			} else {
+				c = exec.CommandContext(ctx, "ping6", "-c", "2", dst)
+			}
+			_ = c.Run()
+		}(name)
+	}
+	wg.Wait()
+}
+
+func ifaceInPrefix(ifi net.Interface, p netip.Prefix) bool {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if ip, ok := netip.AddrFromSlice(ipn.IP); ok && p.Contains(ip.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
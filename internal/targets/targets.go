@@ -0,0 +1,261 @@
+// Package targets expands scan target specifications into address lists.
+//
+// A spec is a comma- or space-separated list of items:
+//
+//	10.0.0.0/24          IPv4 CIDR (network and broadcast addresses skipped)
+//	10.0.0.5-40          range on the last octet
+//	10.0.0.5-10.0.1.20   range between two addresses (IPv4 or IPv6)
+//	10.0.0.7, fd00::7    single address
+//	nas.local            hostname, every address it resolves to
+//	fd00::/64            IPv6 prefix, see below
+//	!10.0.0.1            exclusion, any of the forms above
+//
+// IPv6 prefixes with more than 256 addresses cannot be swept, so their
+// candidates come from the neighbor table (package neigh) instead: the
+// all-nodes multicast address is pinged on the interfaces attached to the
+// prefix first, so that hosts which are up have an entry.
+package targets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// DefaultMax is the default limit on the number of expanded addresses.
+const DefaultMax = 65536
+
+// Options controls Expand.
+type Options struct {
+	Max int // refuse to expand to more addresses; default DefaultMax
+
+	// NoSolicit skips the multicast ping before reading the neighbor
+	// table, e.g. when the table is known to be fresh.
+	NoSolicit bool
+}
+
+// item is one parsed spec entry; exactly one of the fields is set.
+type item struct {
+	prefix netip.Prefix
+	lo, hi netip.Addr // range or single address (lo == hi)
+	host   string
+}
+
+// Expand turns specs into a deduplicated address list in spec order, with
+// exclusions removed. Link-local IPv6 neighbors carry their zone
+// (fe80::1%eth0).
+func Expand(ctx context.Context, specs []string, opts Options) ([]string, error) {
+	if opts.Max <= 0 {
+		opts.Max = DefaultMax
+	}
+	var include, exclude []item
+	for _, spec := range specs {
+		for _, f := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			not := strings.HasPrefix(f, "!")
+			it, err := parseItem(strings.TrimPrefix(f, "!"))
+			if err != nil {
+				return nil, err
+			}
+			if not {
+				exclude = append(exclude, it)
+			} else {
+				include = append(include, it)
+			}
+		}
+	}
+	if len(include) == 0 {
+		return nil, errors.New("no targets given")
+	}
+
+	// 排除项里的主机名先解析成地址
+	var ex []item
+	for _, it := range exclude {
+		if it.host == "" {
+			ex = append(ex, it)
+			continue
+		}
+		addrs, err := resolve(ctx, it.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ex = append(ex, item{lo: a, hi: a})
+		}
+	}
+	exclude = ex
+
+	var out []string
+	seen := map[netip.Addr]bool{}
+	add := func(a netip.Addr) error {
+		if seen[a] || excluded(a, exclude) {
+			return nil
+		}
+		if len(out) >= opts.Max {
+			return fmt.Errorf("targets expand to more than %d addresses", opts.Max)
+		}
+		seen[a] = true
+		out = append(out, a.String())
+		return nil
+	}
+
+	var neigh []netip.Addr
+	neighLoaded := false
+	for _, it := range include {
+		switch {
+		case it.host != "":
+			addrs, err := resolve(ctx, it.host)
+			if err != nil {
+				return nil, err
+			}
+			for _, a := range addrs {
+				if err := add(a); err != nil {
+					return nil, err
+				}
+			}
+		case it.prefix.IsValid() && it.prefix.Addr().Is6() && it.prefix.Bits() < 120:
+			if !neighLoaded {
+				if !opts.NoSolicit {
+					solicit(ctx, include)
+				}
+				var err error
+				if neigh, err = Neighbors6(ctx); err != nil {
+					return nil, fmt.Errorf("%s: %w", it.prefix, err)
+				}
+				neighLoaded = true
+			}
+			for _, a := range neigh {
+				if it.prefix.Contains(a.WithZone("")) {
+					if err := add(a); err != nil {
+						return nil, err
+					}
+				}
+			}
+		default:
+			lo, hi := it.lo, it.hi
+			if it.prefix.IsValid() {
+				lo, hi = prefixBounds(it.prefix)
+			}
+			if size(lo, hi) > int64(opts.Max) {
+				return nil, fmt.Errorf("targets expand to more than %d addresses", opts.Max)
+			}
+			for a := lo; a.IsValid() && a.Compare(hi) <= 0; a = a.Next() {
+				if err := add(a); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func parseItem(s string) (item, error) {
+	if s == "" {
+		return item{}, errors.New("empty target")
+	}
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return item{}, fmt.Errorf("invalid subnet %q: %w", s, err)
+		}
+		return item{prefix: p.Masked()}, nil
+	}
+	if a, err := netip.ParseAddr(s); err == nil {
+		return item{lo: a, hi: a}, nil
+	}
+	if left, right, ok := strings.Cut(s, "-"); ok {
+		if lo, err := netip.ParseAddr(left); err == nil {
+			hi, err := rangeEnd(lo, right)
+			if err != nil {
+				return item{}, fmt.Errorf("invalid range %q: %w", s, err)
+			}
+			if hi.Compare(lo) < 0 {
+				return item{}, fmt.Errorf("invalid range %q: end before start", s)
+			}
+			return item{lo: lo, hi: hi}, nil
+		}
+	}
+	// 其余当主机名（nas-1.local 也会走到这里）
+	if strings.ContainsAny(s, ":[]") {
+		return item{}, fmt.Errorf("invalid target %q", s)
+	}
+	return item{host: s}, nil
+}
+
+// rangeEnd parses the part after "-": a full address of the same family,
+// or for IPv4 just the last octet.
+func rangeEnd(lo netip.Addr, s string) (netip.Addr, error) {
+	if hi, err := netip.ParseAddr(s); err == nil {
+		if hi.Is4() != lo.Is4() {
+			return netip.Addr{}, errors.New("mixed address families")
+		}
+		return hi, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || !lo.Is4() || n < 0 || n > 255 {
+		return netip.Addr{}, fmt.Errorf("bad end %q", s)
+	}
+	b := lo.As4()
+	b[3] = byte(n)
+	return netip.AddrFrom4(b), nil
+}
+
+// prefixBounds 返回要扫描的第一个和最后一个地址；
+// IPv4 /30 及更大的网段去掉网络地址和广播地址
+func prefixBounds(p netip.Prefix) (netip.Addr, netip.Addr) {
+	lo := p.Addr()
+	b := lo.AsSlice()
+	hostBits := len(b)*8 - p.Bits()
+	for i := len(b) - 1; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	hi, _ := netip.AddrFromSlice(b)
+	if lo.Is4() && p.Bits() < 31 {
+		return lo.Next(), hi.Prev()
+	}
+	return lo, hi
+}
+
+func size(lo, hi netip.Addr) int64 {
+	a := new(big.Int).SetBytes(lo.AsSlice())
+	b := new(big.Int).SetBytes(hi.AsSlice())
+	d := b.Sub(b, a)
+	if !d.IsInt64() {
+		return 1 << 62
+	}
+	return d.Int64() + 1
+}
+
+func excluded(a netip.Addr, ex []item) bool {
+	plain := a.WithZone("")
+	for _, it := range ex {
+		switch {
+		case it.prefix.IsValid():
+			if it.prefix.Contains(plain) {
+				return true
+			}
+		case it.lo.IsValid():
+			if plain.Compare(it.lo.WithZone("")) >= 0 && plain.Compare(it.hi.WithZone("")) <= 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for i, a := range addrs {
+		addrs[i] = a.Unmap()
+	}
+	return addrs, nil
+}
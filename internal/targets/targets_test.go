@@ -0,0 +1,104 @@
+package targets
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		specs []string
+		want  string
+	}{
+		{[]string{"192.0.2.7"}, "192.0.2.7"},
+		{[]string{"192.0.2.0/30"}, "192.0.2.1,192.0.2.2"},
+		// /31 和 /32 没有网络地址和广播地址
+		{[]string{"192.0.2.0/31"}, "192.0.2.0,192.0.2.1"},
+		{[]string{"192.0.2.9/32"}, "192.0.2.9"},
+		{[]string{"192.0.2.5-8"}, "192.0.2.5,192.0.2.6,192.0.2.7,192.0.2.8"},
+		{[]string{"192.0.2.254-192.0.3.1"}, "192.0.2.254,192.0.2.255,192.0.3.0,192.0.3.1"},
+		{[]string{"fd00::1-fd00::3"}, "fd00::1,fd00::2,fd00::3"},
+		{[]string{"fd00::/126"}, "fd00::,fd00::1,fd00::2,fd00::3"},
+		{[]string{"192.0.2.3, 192.0.2.1 192.0.2.3", "192.0.2.1-2"}, "192.0.2.3,192.0.2.1,192.0.2.2"},
+		{[]string{"192.0.2.0/29,!192.0.2.2-4"}, "192.0.2.1,192.0.2.5,192.0.2.6"},
+		{[]string{"!192.0.2.0/30", "192.0.2.1-5"}, "192.0.2.4,192.0.2.5"},
+	}
+	for _, tt := range tests {
+		got, err := Expand(context.Background(), tt.specs, Options{NoSolicit: true})
+		if err != nil {
+			t.Errorf("Expand(%q): %v", tt.specs, err)
+			continue
+		}
+		if s := strings.Join(got, ","); s != tt.want {
+			t.Errorf("Expand(%q) = %s, want %s", tt.specs, s, tt.want)
+		}
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	tests := []struct {
+		specs []string
+		max   int
+	}{
+		{nil, 0},
+		{[]string{" , "}, 0},
+		{[]string{"!192.0.2.1"}, 0},
+		{[]string{"192.0.2.0/33"}, 0},
+		{[]string{"192.0.2.9-3"}, 0},
+		{[]string{"192.0.2.1-256"}, 0},
+		{[]string{"192.0.2.1-fd00::1"}, 0},
+		{[]string{"fd00::1-5"}, 0},
+		{[]string{"fd00::zz"}, 0},
+		{[]string{"10.0.0.0/8"}, 0},
+		{[]string{"192.0.2.0/24"}, 100},
+		{[]string{"192.0.2.1-10", "192.0.2.20-30"}, 15},
+	}
+	for _, tt := range tests {
+		if got, err := Expand(context.Background(), tt.specs, Options{Max: tt.max, NoSolicit: true}); err == nil {
+			t.Errorf("Expand(%q, max %d) = %v, want error", tt.specs, tt.max, got)
+		}
+	}
+}
+
+func TestParseItem(t *testing.T) {
+	tests := []struct {
+		s    string
+		want item
+	}{
+		{"192.0.2.0/24", item{prefix: netip.MustParsePrefix("192.0.2.0/24")}},
+		{"192.0.2.77/24", item{prefix: netip.MustParsePrefix("192.0.2.0/24")}},
+		{"192.0.2.7", item{lo: netip.MustParseAddr("192.0.2.7"), hi: netip.MustParseAddr("192.0.2.7")}},
+		{"192.0.2.5-40", item{lo: netip.MustParseAddr("192.0.2.5"), hi: netip.MustParseAddr("192.0.2.40")}},
+		{"nas.local", item{host: "nas.local"}},
+		{"nas-1.local", item{host: "nas-1.local"}},
+	}
+	for _, tt := range tests {
+		got, err := parseItem(tt.s)
+		if err != nil {
+			t.Errorf("parseItem(%q): %v", tt.s, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseItem(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	tests := []struct {
+		lo, hi string
+		want   int64
+	}{
+		{"192.0.2.1", "192.0.2.1", 1},
+		{"192.0.2.1", "192.0.2.254", 254},
+		{"fd00::", "fd00::ffff", 65536},
+		{"fd00::", "fd00::ffff:ffff:ffff:ffff", 1 << 62}, // 溢出时封顶
+	}
+	for _, tt := range tests {
+		if got := size(netip.MustParseAddr(tt.lo), netip.MustParseAddr(tt.hi)); got != tt.want {
+			t.Errorf("size(%s, %s) = %d, want %d", tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package wol sends Wake-on-LAN magic packets: 6 bytes of 0xff followed by
+// the target MAC address 16 times, in a UDP datagram that the sleeping
+// network card recognizes regardless of the destination address and port.
+package wol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// DefaultPort is the usual Wake-on-LAN port (discard); 7 (echo) is also common.
+const DefaultPort = 9
+
+// MagicPacket builds the 102-byte magic packet for mac.
+func MagicPacket(mac string) ([]byte, error) {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, err
+	}
+	if len(hw) != 6 {
+		return nil, fmt.Errorf("not a 48-bit MAC address: %s", mac)
+	}
+	pkt := bytes.Repeat([]byte{0xff}, 6)
+	pkt = append(pkt, bytes.Repeat(hw, 16)...)
+	return pkt, nil
+}
+
+// Broadcast returns the directed broadcast address of an IPv4 prefix, e.g.
+// 192.168.1.255 for 192.168.1.0/24.
+func Broadcast(p netip.Prefix) (netip.Addr, bool) {
+	if !p.Addr().Is4() || p.Bits() >= 31 {
+		return netip.Addr{}, false
+	}
+	b := p.Masked().Addr().As4()
+	host := uint32(1)<<(32-p.Bits()) - 1
+	for i := 0; i < 4; i++ {
+		b[i] |= byte(host >> (24 - 8*i))
+	}
+	return netip.AddrFrom4(b), true
+}
+
+// Send sends the magic packet for mac to every address in dsts (ip:port).
+// It fails only if no packet could be sent at all.
+func Send(mac string, dsts []netip.AddrPort) error {
+	pkt, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+	// Go 的 UDP socket 默认打开 SO_BROADCAST
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var errs []error
+	sent := 0
+	for _, d := range dsts {
+		if _, err := conn.WriteToUDPAddrPort(pkt, d); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d, err))
+			continue
+		}
+		sent++
+	}
+	if sent == 0 {
+		if len(errs) == 0 {
+			return errors.New("no address to send the magic packet to")
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package wol
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac := []byte{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc}
+	for _, s := range []string{"00:11:22:aa:bb:cc", "00-11-22-AA-BB-CC", "0011.22aa.bbcc"} {
+		pkt, err := MagicPacket(s)
+		if err != nil {
+			t.Errorf("MagicPacket(%q): %v", s, err)
+			continue
+		}
+		if len(pkt) != 102 || !bytes.Equal(pkt[:6], bytes.Repeat([]byte{0xff}, 6)) {
+			t.Errorf("MagicPacket(%q) header = % x", s, pkt[:6])
+			continue
+		}
+		for i := 6; i < len(pkt); i += 6 {
+			if !bytes.Equal(pkt[i:i+6], mac) {
+				t.Errorf("MagicPacket(%q) copy at %d = % x", s, i, pkt[i:i+6])
+				break
+			}
+		}
+	}
+	for _, s := range []string{"", "00:11:22:33:44", "00:11:22:33:44:55:66:77", "zz:11:22:33:44:55"} {
+		if _, err := MagicPacket(s); err == nil {
+			t.Errorf("MagicPacket(%q) = nil error", s)
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string // "" 为没有广播地址
+	}{
+		{"192.168.1.0/24", "192.168.1.255"},
+		{"192.168.1.77/24", "192.168.1.255"},
+		{"10.0.0.0/8", "10.255.255.255"},
+		{"172.16.4.1/22", "172.16.7.255"},
+		{"192.0.2.9/30", "192.0.2.11"},
+		{"0.0.0.0/0", "255.255.255.255"},
+		{"192.0.2.0/31", ""},
+		{"192.0.2.1/32", ""},
+		{"fd00::/64", ""},
+	}
+	for _, tt := range tests {
+		got, ok := Broadcast(netip.MustParsePrefix(tt.prefix))
+		if tt.want == "" {
+			if ok {
+				t.Errorf("Broadcast(%s) = %s, want none", tt.prefix, got)
+			}
+			continue
+		}
+		if !ok || got.String() != tt.want {
+			t.Errorf("Broadcast(%s) = %s %v, want %s", tt.prefix, got, ok, tt.want)
+		}
+	}
+}